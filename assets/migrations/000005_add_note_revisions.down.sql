@@ -0,0 +1,2 @@
+-- Drop the note revisions table
+DROP TABLE IF EXISTS note_revisions;
\ No newline at end of file
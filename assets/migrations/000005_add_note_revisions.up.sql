@@ -0,0 +1,12 @@
+-- Create the note revisions table
+CREATE TABLE IF NOT EXISTS note_revisions (
+    id TEXT PRIMARY KEY CHECK (id ~ '^r_'),
+    note_id TEXT NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    title TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    tags TEXT [] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Create an index to list the revisions for a note
+CREATE INDEX IF NOT EXISTS note_revisions_note_id_idx ON note_revisions (note_id, created_at desc);
\ No newline at end of file
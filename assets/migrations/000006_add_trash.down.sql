@@ -0,0 +1,14 @@
+-- Restore the tag summary view without the trash filter
+CREATE OR REPLACE VIEW tag_summary AS
+SELECT unnest(tags) AS tag_name,
+    COUNT(*) AS note_count
+FROM notes
+WHERE tags IS NOT NULL
+    AND array_length(tags, 1) > 0
+GROUP BY tag_name
+ORDER BY note_count DESC,
+    tag_name;
+-- Drop the index on the deleted_at column
+DROP INDEX IF EXISTS notes_deleted_at_idx;
+-- Drop the deleted_at column
+ALTER TABLE IF EXISTS notes DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
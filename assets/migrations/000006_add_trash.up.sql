@@ -0,0 +1,16 @@
+-- Add a deleted_at column for notes in the trash
+ALTER TABLE IF EXISTS notes
+ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+-- Create an index on the deleted_at column
+CREATE INDEX IF NOT EXISTS notes_deleted_at_idx ON notes (deleted_at);
+-- Update the tag summary view to ignore notes in the trash
+CREATE OR REPLACE VIEW tag_summary AS
+SELECT unnest(tags) AS tag_name,
+    COUNT(*) AS note_count
+FROM notes
+WHERE tags IS NOT NULL
+    AND array_length(tags, 1) > 0
+    AND deleted_at IS NULL
+GROUP BY tag_name
+ORDER BY note_count DESC,
+    tag_name;
\ No newline at end of file
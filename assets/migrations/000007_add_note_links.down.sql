@@ -0,0 +1,4 @@
+-- Drop the lower title index
+DROP INDEX IF EXISTS notes_title_lower_idx;
+-- Drop the note links table
+DROP TABLE IF EXISTS note_links;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- Create the note links table for [[wiki links]] between notes
+CREATE TABLE IF NOT EXISTS note_links (
+    source_id TEXT NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    target TEXT NOT NULL,
+    PRIMARY KEY (source_id, target)
+);
+
+-- Create indexes to find the backlinks to a note by ID or title
+CREATE INDEX IF NOT EXISTS note_links_target_idx ON note_links (lower(target));
+CREATE INDEX IF NOT EXISTS notes_title_lower_idx ON notes (lower(title));
+
+-- Fill in the links for the existing notes
+INSERT INTO note_links (source_id, target)
+SELECT DISTINCT id,
+    target
+FROM (
+        SELECT id,
+            btrim((regexp_matches(note, '\[\[([^\[\]|]+)(?:\|[^\[\]]*)?\]\]', 'g'))[1]) AS target
+        FROM notes
+    ) AS links
+WHERE target <> '' ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- Drop the attachment data table
+DROP TABLE IF EXISTS attachment_data;
+-- Drop the attachments table
+DROP TABLE IF EXISTS attachments;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- Create the attachments table for files uploaded to notes
+CREATE TABLE IF NOT EXISTS attachments (
+    id TEXT PRIMARY KEY CHECK (id ~ '^a_'),
+    note_id TEXT NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    filename TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT 'application/octet-stream',
+    size BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Create an index to list the attachments for a note
+CREATE INDEX IF NOT EXISTS attachments_note_id_idx ON attachments (note_id, created_at);
+
+-- Create the attachment data table for attachments stored in the database
+CREATE TABLE IF NOT EXISTS attachment_data (
+    attachment_id TEXT PRIMARY KEY REFERENCES attachments (id) ON DELETE CASCADE,
+    data BYTEA NOT NULL
+);
\ No newline at end of file
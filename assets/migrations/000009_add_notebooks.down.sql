@@ -0,0 +1,4 @@
+-- Drop the notebook_id column from notes
+ALTER TABLE IF EXISTS notes DROP COLUMN IF EXISTS notebook_id;
+-- Drop the notebooks table
+DROP TABLE IF EXISTS notebooks;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- Create the notebooks table for organizing notes into folders
+CREATE TABLE IF NOT EXISTS notebooks (
+    id TEXT PRIMARY KEY CHECK (id ~ '^nb_'),
+    parent_id TEXT REFERENCES notebooks (id) ON DELETE SET NULL,
+    name TEXT NOT NULL CHECK (name <> ''),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (parent_id <> id)
+);
+
+-- Create an index to list the notebooks in a notebook
+CREATE INDEX IF NOT EXISTS notebooks_parent_id_idx ON notebooks (parent_id);
+
+-- Add a notebook_id column to notes
+ALTER TABLE IF EXISTS notes
+ADD COLUMN IF NOT EXISTS notebook_id TEXT REFERENCES notebooks (id) ON DELETE SET NULL;
+
+-- Create an index to list the notes in a notebook
+CREATE INDEX IF NOT EXISTS notes_notebook_id_idx ON notes (notebook_id);
\ No newline at end of file
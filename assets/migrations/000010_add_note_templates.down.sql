@@ -0,0 +1,4 @@
+-- Drop the is_template index
+DROP INDEX IF EXISTS notes_is_template_idx;
+-- Drop the is_template column
+ALTER TABLE IF EXISTS notes DROP COLUMN IF EXISTS is_template;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- Add an is_template column to flag notes used as templates for new notes
+ALTER TABLE IF EXISTS notes
+ADD COLUMN IF NOT EXISTS is_template BOOLEAN NOT NULL DEFAULT FALSE;
+-- Create an index to list the template notes
+CREATE INDEX IF NOT EXISTS notes_is_template_idx ON notes (is_template)
+WHERE is_template;
\ No newline at end of file
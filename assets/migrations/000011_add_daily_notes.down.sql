@@ -0,0 +1,4 @@
+-- Drop the daily_date index
+DROP INDEX IF EXISTS notes_daily_date_idx;
+-- Drop the daily_date column
+ALTER TABLE IF EXISTS notes DROP COLUMN IF EXISTS daily_date;
\ No newline at end of file
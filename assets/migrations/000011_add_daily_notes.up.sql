@@ -0,0 +1,7 @@
+-- Add a daily_date column for daily journal notes
+ALTER TABLE IF EXISTS notes
+ADD COLUMN IF NOT EXISTS daily_date DATE;
+-- Only allow one daily note for each date outside of the trash
+CREATE UNIQUE INDEX IF NOT EXISTS notes_daily_date_idx ON notes (daily_date)
+WHERE daily_date IS NOT NULL
+    AND deleted_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- Drop the remind_at index
+DROP INDEX IF EXISTS notes_remind_at_idx;
+-- Drop the reminder columns
+ALTER TABLE IF EXISTS notes DROP COLUMN IF EXISTS reminder_sent_at,
+    DROP COLUMN IF EXISTS remind_at;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- Add columns for note reminders. reminder_sent_at records when a reminder email went out
+-- so that the same reminder is never sent twice.
+ALTER TABLE IF EXISTS notes
+ADD COLUMN IF NOT EXISTS remind_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMPTZ;
+-- Create an index to find the reminders that still need to be sent
+CREATE INDEX IF NOT EXISTS notes_remind_at_idx ON notes (remind_at)
+WHERE remind_at IS NOT NULL
+    AND reminder_sent_at IS NULL;
\ No newline at end of file
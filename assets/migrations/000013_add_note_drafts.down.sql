@@ -0,0 +1,2 @@
+-- Drop the note drafts table
+DROP TABLE IF EXISTS note_drafts;
\ No newline at end of file
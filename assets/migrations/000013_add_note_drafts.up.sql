@@ -0,0 +1,16 @@
+-- Create the note drafts table for unsaved changes in the note editor.
+-- The id is the ID of the note being edited, or 'new' for a note that hasn't been saved yet.
+CREATE TABLE IF NOT EXISTS note_drafts (
+    id TEXT PRIMARY KEY,
+    note_id TEXT REFERENCES notes (id) ON DELETE CASCADE,
+    title TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    modified_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (
+        (
+            id = 'new'
+            AND note_id IS NULL
+        )
+        OR id = note_id
+    )
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Drop the data migrations table
+DROP TABLE IF EXISTS data_migrations;
\ No newline at end of file
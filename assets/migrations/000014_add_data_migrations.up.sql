@@ -0,0 +1,6 @@
+-- Record the data migrations that have been run by the application, like re-extracting
+-- the tags of every note after the hashtag rules change.
+CREATE TABLE IF NOT EXISTS data_migrations (
+    name TEXT PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Remove the explicit tags column
+ALTER TABLE notes DROP COLUMN IF EXISTS explicit_tags;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- Add tags that are set on a note directly instead of with a #hashtag in the text.
+-- The tags column has these tags and the hashtags from the text.
+ALTER TABLE notes
+ADD COLUMN IF NOT EXISTS explicit_tags TEXT [] NOT NULL DEFAULT '{}';
\ No newline at end of file
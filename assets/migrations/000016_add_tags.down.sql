@@ -0,0 +1,2 @@
+-- Drop the tag metadata table
+DROP TABLE IF EXISTS tags;
\ No newline at end of file
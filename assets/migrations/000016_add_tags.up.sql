@@ -0,0 +1,8 @@
+-- Create the tag metadata table. The name is the normalized tag name that is stored in notes.tags.
+CREATE TABLE IF NOT EXISTS tags (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    color TEXT NOT NULL DEFAULT '',
+    pinned BOOLEAN NOT NULL DEFAULT FALSE,
+    modified_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Drop the automatic tagging rules table
+DROP TABLE IF EXISTS tag_rules;
\ No newline at end of file
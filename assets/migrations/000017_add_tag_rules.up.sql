@@ -0,0 +1,9 @@
+-- Create the automatic tagging rules. A rule adds its tag to notes that match
+-- the kind of rule (title_prefix, title_contains, note_contains or url_domain) and the value.
+CREATE TABLE IF NOT EXISTS tag_rules (
+    id TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    value TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- Drop the full text search index and columns
+DROP INDEX IF EXISTS notes_search_vector_idx;
+ALTER TABLE notes DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS search_language;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- Add a full text search vector to notes. Title matches are weighted (A) above matches in
+-- the note (B). The search language is set from the -search-language flag when the app starts.
+ALTER TABLE notes
+ADD COLUMN IF NOT EXISTS search_language REGCONFIG NOT NULL DEFAULT 'english',
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector(search_language, title), 'A') || setweight(to_tsvector(search_language, note), 'B')
+    ) STORED;
+
+-- Create a GIN index for full text searches
+CREATE INDEX IF NOT EXISTS notes_search_vector_idx ON notes USING GIN (search_vector);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Drop the saved searches table
+DROP TABLE IF EXISTS saved_searches;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Drop the note redirects table
+DROP TABLE IF EXISTS note_redirects;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- Create the note redirects. When a note is merged into another note, links to its old ID
+-- are redirected to the note it was merged into.
+CREATE TABLE IF NOT EXISTS note_redirects (
+    old_id TEXT PRIMARY KEY,
+    note_id TEXT NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS note_redirects_note_id_idx ON note_redirects (note_id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Remove the reminder attempt columns
+ALTER TABLE notes DROP COLUMN IF EXISTS reminder_attempts,
+    DROP COLUMN IF EXISTS reminder_retry_at;
\ No newline at end of file
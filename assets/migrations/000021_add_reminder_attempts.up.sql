@@ -0,0 +1,5 @@
+-- Count the failed attempts to send a reminder email. A failed reminder is sent again at
+-- reminder_retry_at, with a longer wait after each failure, until it is given up on.
+ALTER TABLE notes
+ADD COLUMN IF NOT EXISTS reminder_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS reminder_retry_at TIMESTAMPTZ;
\ No newline at end of file
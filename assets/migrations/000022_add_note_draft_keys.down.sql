@@ -0,0 +1,18 @@
+-- Remove the drafts that don't fit the one draft per note key and the tags column
+DROP INDEX IF EXISTS note_drafts_note_id_idx;
+DELETE FROM note_drafts
+WHERE NOT (
+        (
+            id = 'new'
+            AND note_id IS NULL
+        )
+        OR id = note_id
+    );
+ALTER TABLE note_drafts DROP COLUMN IF EXISTS tags,
+    ADD CONSTRAINT note_drafts_check CHECK (
+        (
+            id = 'new'
+            AND note_id IS NULL
+        )
+        OR id = note_id
+    );
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- Key note drafts by the editor they were autosaved from instead of by note, so editors
+-- in different tabs don't overwrite each other's drafts, and save the tags with the draft
+ALTER TABLE note_drafts DROP CONSTRAINT IF EXISTS note_drafts_check,
+    ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '';
+-- Create an index to find the newest draft of a note
+CREATE INDEX IF NOT EXISTS note_drafts_note_id_idx ON note_drafts (note_id, modified_at);
\ No newline at end of file
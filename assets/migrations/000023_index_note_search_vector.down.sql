@@ -0,0 +1,7 @@
+-- Store the full text search vector of notes in a column again
+DROP INDEX IF EXISTS notes_search_vector_idx;
+ALTER TABLE notes
+ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector(search_language, title), 'A') || setweight(to_tsvector(search_language, note), 'B')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS notes_search_vector_idx ON notes USING GIN (search_vector);
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- Index the full text search vector of notes instead of storing it in a column, so the
+-- vector isn't read every time a note is loaded. Searches use the same expression.
+DROP INDEX IF EXISTS notes_search_vector_idx;
+ALTER TABLE notes DROP COLUMN IF EXISTS search_vector;
+CREATE INDEX IF NOT EXISTS notes_search_vector_idx ON notes USING GIN (
+    (
+        setweight(to_tsvector(search_language, title), 'A') || setweight(to_tsvector(search_language, note), 'B')
+    )
+);
\ No newline at end of file
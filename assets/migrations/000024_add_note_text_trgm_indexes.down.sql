@@ -0,0 +1,3 @@
+-- Drop the trigram indexes on the titles and text of notes
+drop index if exists notes_text_trgm_idx;
+drop index if exists notes_title_trgm_idx;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Create trigram indexes on the titles and on the text of notes without the ID to find related notes
+create index if not exists notes_title_trgm_idx on notes using GIN (title gin_trgm_ops);
+create index if not exists notes_text_trgm_idx on notes using GIN ((title || ' ' || note) gin_trgm_ops);
\ No newline at end of file
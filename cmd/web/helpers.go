@@ -1,16 +1,37 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"regexp"
 	"runtime/debug"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/jackc/pgx/v5"
 	"github.com/justinas/nosurf"
+	"github.com/sglmr/go-notes/db"
+	"github.com/sglmr/go-notes/internal/search"
+	"github.com/sglmr/go-notes/internal/storage"
+	"github.com/sglmr/go-notes/internal/tasklist"
+	"github.com/sglmr/go-notes/internal/validator"
 	"github.com/sglmr/go-notes/internal/vcs"
+	"github.com/sglmr/go-notes/internal/wikilink"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
 type contextKey string
@@ -22,6 +43,8 @@ type contextKey string
 const (
 	isAuthenticatedContextKey = contextKey("isAuthenticated")
 	isAnonyousContextKey      = contextKey("isAnonymous")
+	navContextKey             = contextKey("nav")
+	wikiLinksContextKey       = contextKey("wikiLinks")
 )
 
 // isAuthenticated returns true when a user is authenticated. The function checks the
@@ -61,6 +84,14 @@ func clientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
 
+// localRedirectURL returns next if it is a path on this site, otherwise fallback
+func localRedirectURL(next, fallback string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return fallback
+	}
+	return next
+}
+
 //=============================================================================
 // Template Helpers
 //=============================================================================
@@ -72,56 +103,1474 @@ func newTemplateData(r *http.Request, sessionManager *scs.SessionManager) map[st
 		messages = []FlashMessage{}
 	}
 
+	// The nav loader is added by navMW and the wiki link resolver by wikiLinksMW
+	nav, _ := r.Context().Value(navContextKey).(*navData)
+	wikiLinks, _ := r.Context().Value(wikiLinksContextKey).(wikilink.Resolver)
+
 	return map[string]any{
 		"CSRFToken":       nosurf.Token(r),
 		"IsAuthenticated": isAuthenticated(r),
 		"Messages":        messages,
+		"Nav":             nav,
 		"TimeLocation":    timeLocation,
 		"UrlPath":         r.URL.Path,
 		"Version":         vcs.Version(),
+		"WikiLinks":       wikiLinks,
 	}
 }
 
-// extractTags extracts hashtags from the input text and returns them as a slice of strings
-// The hashtags are extracted without the # symbol.
-func extractTags(text string) []string {
-	// Compile the regular expression
-	re := regexp.MustCompile(`(^|\s|.)#([-a-z0-9]*[a-z][-a-z0-9]*)`)
+// navData loads the pinned tags and searches for the nav when a page renders it, so
+// requests that don't render a page, like autosaves and downloads, don't query for them
+type navData struct {
+	ctx     context.Context
+	logger  *slog.Logger
+	queries *db.Queries
+}
 
-	// Find all matches of the regex in the input text
-	// The second argument -1 means return all matches
-	matches := re.FindAllStringSubmatch(text, -1)
+// PinnedTags returns the pinned tags, or none if they can't be loaded
+func (n *navData) PinnedTags() []db.Tag {
+	tags, err := n.queries.ListPinnedTags(n.ctx)
+	if err != nil {
+		// The page still works without the pinned tags
+		n.logger.Error("list pinned tags error", "error", err)
+		return nil
+	}
+	return tags
+}
 
-	// Initialize the results slice
-	result := []string{}
+// PinnedSearches returns the pinned saved searches with their counts, or none if they
+// can't be loaded
+func (n *navData) PinnedSearches() []savedSearchCount {
+	searches, err := n.queries.ListPinnedSavedSearches(n.ctx)
+	if err != nil {
+		// The page still works without the pinned searches
+		n.logger.Error("list pinned searches error", "error", err)
+		return nil
+	}
+	counts, err := countSavedSearches(n.ctx, n.queries, searches)
+	if err != nil {
+		n.logger.Error("count pinned searches error", "error", err)
+		return nil
+	}
+	return counts
+}
+
+// tagPart matches one part of a tag name: letters, numbers and dashes with at least one letter
+const tagPart = `[-\p{L}\p{M}\p{N}]*\p{L}[-\p{L}\p{M}\p{N}]*`
 
-	// Extract the capture group (the hashtag without #) from each match
-	for _, match := range matches {
+// hashtagRX matches a #hashtag and the character before it. Nested tags
+// like #project/alpha separate the parts of the tag with a /.
+var hashtagRX = regexp.MustCompile(`(^|\s|.)#(` + tagPart + `(?:/` + tagPart + `)*)`)
+
+// codeSpanRX matches inline `code` spans on a line
+var codeSpanRX = regexp.MustCompile("``[^\n]*?``|`[^`\n]+`")
+
+// codeRanges returns the start and end of the fenced code blocks and inline code spans in the text
+func codeRanges(text string) [][2]int {
+	ranges := [][2]int{}
+	fence := ""
+	fenceStart := 0
+	offset := 0
+	for line := range strings.SplitAfterSeq(text, "\n") {
+		trimmed := strings.TrimSpace(line)
 		switch {
-		case len(match) == 0:
-			continue
-		case match[1] == "(":
+		case fence != "":
+			// Inside a fenced code block until the closing fence
+			if strings.HasPrefix(trimmed, fence) {
+				ranges = append(ranges, [2]int{fenceStart, offset + len(line)})
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			fenceStart = offset
+		default:
+			for _, m := range codeSpanRX.FindAllStringIndex(line, -1) {
+				ranges = append(ranges, [2]int{offset + m[0], offset + m[1]})
+			}
+		}
+		offset += len(line)
+	}
+
+	// An unclosed fence runs to the end of the text
+	if fence != "" {
+		ranges = append(ranges, [2]int{fenceStart, len(text)})
+	}
+	return ranges
+}
+
+// hashtagIndexes returns the start and end of each #hashtag in the text, including the # symbol.
+// Hashtags in code, links to ids and tags of 1 char or less are skipped.
+func hashtagIndexes(text string) [][2]int {
+	code := codeRanges(text)
+
+	result := [][2]int{}
+	for _, m := range hashtagRX.FindAllStringSubmatchIndex(text, -1) {
+		before := text[m[2]:m[3]]
+		start, end := m[3], m[5]
+		switch {
+		case before == "(":
 			// Exclude links to ids in markdown
 			// ex: [link](#heading-link)
 			continue
-		case match[1] == `"`:
+		case before == `"`:
 			// Exclude links to ids in a href tags
 			// ex: <a href="#heading-link">link</a>
 			continue
-		case len(match[2]) <= 1:
+		case utf8.RuneCountInString(text[start+1:end]) <= 1:
 			// Skip tags that are 1 char or less
 			continue
-		case slices.Contains(result, match[2]):
-			// Skip tags that are already in the result slice
+		case slices.ContainsFunc(code, func(r [2]int) bool { return start >= r[0] && start < r[1] }):
+			// Skip hashtags in code
+			// ex: `#include`
 			continue
 		default:
-			result = append(result, match[2])
+			result = append(result, [2]int{start, end})
 		}
 	}
+	return result
+}
 
+// normalizeTag returns the name a tag is stored and matched by. Tags are case folded and
+// NFC normalized, so #ToDo and #todo are the same tag.
+func normalizeTag(tag string) string {
+	return norm.NFC.String(cases.Fold().String(tag))
+}
+
+// extractTags extracts hashtags from the input text and returns them as a slice of strings
+// The hashtags are extracted without the # symbol and normalized with normalizeTag.
+func extractTags(text string) []string {
+	result := []string{}
+	for _, index := range hashtagIndexes(text) {
+		// Skip tags that are already in the result slice
+		if tag := normalizeTag(text[index[0]+1 : index[1]]); !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
 	return result
 }
 
+// tagLabels returns the hashtags in the text as they are written, by their normalized names.
+// The first spelling of a tag in the text is used.
+func tagLabels(text string, labels map[string]string) map[string]string {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for _, index := range hashtagIndexes(text) {
+		label := norm.NFC.String(text[index[0]+1 : index[1]])
+		if tag := normalizeTag(label); labels[tag] == "" {
+			labels[tag] = label
+		}
+	}
+	return labels
+}
+
+//=============================================================================
+// Note Helpers
+//=============================================================================
+
+// snapshotNote saves the current version of a note as a revision before the note is overwritten
+func snapshotNote(ctx context.Context, queries *db.Queries, noteID string) error {
+	revisionID, err := db.GenerateID("r")
+	if err != nil {
+		return err
+	}
+
+	params := db.CreateNoteRevisionParams{
+		ID:     revisionID,
+		NoteID: noteID,
+	}
+	if _, err := queries.CreateNoteRevision(ctx, params); err != nil {
+		return fmt.Errorf("create note revision: %w", err)
+	}
+	return nil
+}
+
+// dailyTag is the reserved tag for daily notes
+const dailyTag = "daily"
+
+// noteTags returns the hashtags in the note text and the tags set on the note directly.
+// Daily notes always have the reserved daily tag.
+func noteTags(text string, explicit []string, daily bool) []string {
+	tags := extractTags(text)
+	for _, tag := range explicit {
+		if tag = normalizeTag(tag); !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if daily && !slices.Contains(tags, dailyTag) {
+		tags = append(tags, dailyTag)
+	}
+	return tags
+}
+
+// noteTagLabels adds the tags of a note as they are written to the labels by their normalized names
+func noteTagLabels(note db.Note, labels map[string]string) map[string]string {
+	labels = tagLabels(note.Note, labels)
+	for _, label := range note.ExplicitTags {
+		if tag := normalizeTag(label); labels[tag] == "" {
+			labels[tag] = label
+		}
+	}
+	return labels
+}
+
+// updateNoteText replaces the text and explicit tags of a note and updates the tags to match.
+// A revision of the note is saved first.
+func updateNoteText(ctx context.Context, queries *db.Queries, note db.Note, text string, explicit []string) error {
+	rules, err := queries.ListTagRules(ctx)
+	if err != nil {
+		return fmt.Errorf("list tag rules: %w", err)
+	}
+	if err := snapshotNote(ctx, queries, note.ID); err != nil {
+		return err
+	}
+	if text != note.Note {
+		if _, err := queries.UpdateNoteText(ctx, db.UpdateNoteTextParams{ID: note.ID, Note: text}); err != nil {
+			return fmt.Errorf("update note text: %w", err)
+		}
+	}
+	if !slices.Equal(explicit, note.ExplicitTags) {
+		params := db.UpdateNoteExplicitTagsParams{ID: note.ID, ExplicitTags: explicit}
+		if err := queries.UpdateNoteExplicitTags(ctx, params); err != nil {
+			return fmt.Errorf("update note explicit tags: %w", err)
+		}
+	}
+	params := db.UpdateNoteTagsParams{
+		ID:   note.ID,
+		Tags: ruleTags(noteTags(text, explicit, note.DailyDate != nil), rules, note.Title, text),
+	}
+	if _, err := queries.UpdateNoteTags(ctx, params); err != nil {
+		return fmt.Errorf("update note tags: %w", err)
+	}
+	return nil
+}
+
+// refreshAllNoteTags extracts the tags from the text of every note again, applies the
+// tagging rules and returns the number of notes with changed tags. Notes edited in the
+// meantime are skipped.
+//
+// The tags are updated in one query without changing the notes' modified time or saving
+// revisions. Unlike a tag rename, the note text and explicit tags don't change: the tags are
+// derived from them and the tagging rules, so a revision would hold the same content as the
+// note and restoring it would get the same tags again. Saving a revision of every matching
+// note for each rule change would also bury the edits in the history, and bumping the
+// modified time would make open editors report conflicts that aren't there.
+func refreshAllNoteTags(ctx context.Context, logger *slog.Logger, queries *db.Queries) (int, error) {
+	// Get a list of all the notes
+	notes, err := queries.ListAllNotes(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Get the automatic tagging rules
+	rules, err := queries.ListTagRules(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Find the notes with changed tags
+	type noteTagsRow struct {
+		ID         string    `json:"id"`
+		Tags       []string  `json:"tags"`
+		ModifiedAt time.Time `json:"modified_at"`
+	}
+	changed := []noteTagsRow{}
+	for _, note := range notes {
+		tags := ruleTags(noteTags(note.Note, note.ExplicitTags, note.DailyDate != nil), rules, note.Title, note.Note)
+		if slices.Equal(note.Tags, tags) {
+			continue
+		}
+		changed = append(changed, noteTagsRow{ID: note.ID, Tags: tags, ModifiedAt: note.ModifiedAt})
+		logger.Debug("updating note tags", "note", note.Title, "note_id", note.ID)
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	// Update the tags of all the changed notes together
+	rows, err := json.Marshal(changed)
+	if err != nil {
+		return 0, err
+	}
+	count, err := queries.SetNoteTags(ctx, rows)
+	if err != nil {
+		return 0, fmt.Errorf("set note tags: %w", err)
+	}
+	return int(count), nil
+}
+
+// runDataMigration runs a one-time change to the data in a transaction, unless
+// a migration with the same name has already been run
+func runDataMigration(ctx context.Context, queries *db.Queries, name string, fn func(queries *db.Queries) error) (bool, error) {
+	ran := false
+	err := queries.InTx(ctx, func(queries *db.Queries) error {
+		claimed, err := queries.ClaimDataMigration(ctx, name)
+		if err != nil || claimed == 0 {
+			return err
+		}
+		ran = true
+		return fn(queries)
+	})
+	if err != nil {
+		return false, fmt.Errorf("data migration %s: %w", name, err)
+	}
+	return ran, nil
+}
+
+// tagRX matches a valid tag name without the # symbol
+var tagRX = regexp.MustCompile(`^` + tagPart + `(?:/` + tagPart + `)*$`)
+
+// validTag reports whether a tag name can be used as a #hashtag in a note
+func validTag(tag string) bool {
+	return utf8.RuneCountInString(tag) > 1 && tagRX.MatchString(tag)
+}
+
+// addTagToText adds a #hashtag to the end of the note text when the note doesn't have the tag
+func addTagToText(text, tag string) string {
+	if slices.Contains(extractTags(text), normalizeTag(tag)) {
+		return text
+	}
+	return strings.TrimRight(text, "\n") + "\n\n#" + tag
+}
+
+// removeTagFromText removes every #hashtag for a tag from the note text
+func removeTagFromText(text, tag string) string {
+	var b strings.Builder
+	last := 0
+	tag = normalizeTag(tag)
+	for _, index := range hashtagIndexes(text) {
+		if normalizeTag(text[index[0]+1:index[1]]) != tag {
+			continue
+		}
+		// Remove a space before the hashtag with it
+		start := index[0]
+		if start > last && (text[start-1] == ' ' || text[start-1] == '\t') {
+			start--
+		}
+		b.WriteString(text[last:start])
+		last = index[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// renameTagInText replaces every #hashtag for a tag in the note text with another tag.
+// Nested tags are renamed with their parent, so #project/alpha becomes #work/alpha.
+func renameTagInText(text, from, to string) string {
+	from = normalizeTag(from)
+
+	var b strings.Builder
+	last := 0
+	for _, index := range hashtagIndexes(text) {
+		tag, ok := renamedTag(text[index[0]+1:index[1]], from, to)
+		if !ok {
+			continue
+		}
+		b.WriteString(text[last:index[0]])
+		b.WriteString("#" + tag)
+		last = index[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// renamedTag returns the new name of a tag when it is the renamed tag, or nested inside it.
+// The from tag is a normalized tag name.
+func renamedTag(tag, from, to string) (string, bool) {
+	if !hasTagPrefix(normalizeTag(tag), from) {
+		return tag, false
+	}
+	// Keep the nested parts of the tag as they are written
+	parts := strings.Split(tag, "/")
+	return strings.Join(append([]string{to}, parts[strings.Count(from, "/")+1:]...), "/"), true
+}
+
+// renameTags renames a tag and the tags nested inside it in a list of explicit tags
+func renameTags(tags []string, from, to string) []string {
+	from = normalizeTag(from)
+	renamed := []string{}
+	for _, tag := range tags {
+		tag, _ = renamedTag(tag, from, to)
+		if !slices.ContainsFunc(renamed, func(t string) bool { return normalizeTag(t) == normalizeTag(tag) }) {
+			renamed = append(renamed, tag)
+		}
+	}
+	return renamed
+}
+
+// removeTag removes a tag from a list of explicit tags
+func removeTag(tags []string, tag string) []string {
+	tag = normalizeTag(tag)
+	return slices.DeleteFunc(slices.Clone(tags), func(t string) bool { return normalizeTag(t) == tag })
+}
+
+// hasTagPrefix reports whether a tag is the parent tag or nested inside it
+func hasTagPrefix(tag, parent string) bool {
+	return tag == parent || strings.HasPrefix(tag, parent+"/")
+}
+
+// tagNode is a tag with its nested tags
+type tagNode struct {
+	Name      string
+	Label     string
+	NoteCount int64
+	Total     int64
+	Children  []*tagNode
+
+	// Selected and Excluded are set for the tags being filtered on, and Open for them and their parents
+	Selected bool
+	Excluded bool
+	Open     bool
+}
+
+// selectTags marks the selected and excluded tags in the tree and opens their parents
+func selectTags(nodes []*tagNode, selected, excluded []string) {
+	for _, n := range nodes {
+		n.Selected = slices.Contains(selected, n.Name)
+		n.Excluded = slices.Contains(excluded, n.Name)
+		n.Open = slices.ContainsFunc(slices.Concat(selected, excluded), func(tag string) bool {
+			return hasTagPrefix(tag, n.Name)
+		})
+		selectTags(n.Children, selected, excluded)
+	}
+}
+
+// tagTree arranges the tags into a tree by their / separated parts and returns the top
+// level tags in name order. Parent tags that aren't counted are added with a count of 0.
+func tagTree(tags []db.TagCount) []*tagNode {
+	nodes := map[string]*tagNode{}
+	roots := []*tagNode{}
+
+	// Find or add the node for a tag and its parents
+	var node func(name string) *tagNode
+	node = func(name string) *tagNode {
+		if n, ok := nodes[name]; ok {
+			return n
+		}
+		n := &tagNode{Name: name, Label: name}
+		nodes[name] = n
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			n.Label = name[i+1:]
+			parent := node(name[:i])
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+		return n
+	}
+
+	for _, tag := range tags {
+		if tag.TagName == "" {
+			continue
+		}
+		n := node(tag.TagName)
+		n.NoteCount, n.Total = tag.NoteCount, tag.Total
+	}
+
+	// Sort each level of the tree
+	var sortNodes func(nodes []*tagNode)
+	sortNodes = func(nodes []*tagNode) {
+		slices.SortFunc(nodes, func(a, b *tagNode) int { return strings.Compare(a.Name, b.Name) })
+		for _, n := range nodes {
+			sortNodes(n.Children)
+		}
+	}
+	sortNodes(roots)
+
+	return roots
+}
+
+// facetTags returns every tag with the number of notes for it that match the current search.
+// Tags that no matching notes have are kept with a count of 0 so they can still be chosen.
+func facetTags(all []db.TagSummary, matching []db.TagCount) []db.TagCount {
+	tags := slices.Clone(matching)
+	counted := map[string]bool{}
+	for _, tag := range matching {
+		counted[tag.TagName] = true
+	}
+	for _, tag := range all {
+		if name, _ := tag.TagName.(string); !counted[name] {
+			tags = append(tags, db.TagCount{TagName: name})
+		}
+	}
+	slices.SortFunc(tags, func(a, b db.TagCount) int { return strings.Compare(a.TagName, b.TagName) })
+	return tags
+}
+
+// filterTags returns the unique tag names of a repeated tag filter parameter
+func filterTags(values []string) []string {
+	tags := []string{}
+	for _, value := range values {
+		if tag := normalizeTag(parseTag(value)); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// tagChip is a selected or excluded tag filter with a link to remove it
+type tagChip struct {
+	Name      string
+	Excluded  bool
+	RemoveURL string
+}
+
+// tagChips returns the chips for the tag filters of a notes list URL
+func tagChips(u *url.URL, selected, excluded []string) []tagChip {
+	chip := func(param, tag string, excluded bool) tagChip {
+		query := u.Query()
+		query[param] = slices.DeleteFunc(slices.Clone(query[param]), func(value string) bool {
+			return normalizeTag(parseTag(value)) == tag
+		})
+		return tagChip{Name: tag, Excluded: excluded, RemoveURL: u.Path + "?" + query.Encode()}
+	}
+
+	chips := []tagChip{}
+	for _, tag := range selected {
+		chips = append(chips, chip("tag", tag, false))
+	}
+	for _, tag := range excluded {
+		chips = append(chips, chip("notag", tag, true))
+	}
+	return chips
+}
+
+// parseTag returns a tag name from a form value as it is written, without the # symbol
+func parseTag(value string) string {
+	return norm.NFC.String(strings.TrimPrefix(strings.TrimSpace(value), "#"))
+}
+
+// colorRX matches a #rrggbb tag color from a color input
+var colorRX = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// tagColors returns the colors of the tags that have one by tag name
+func tagColors(tags []db.Tag) map[string]string {
+	colors := map[string]string{}
+	for _, tag := range tags {
+		if tag.Color != "" {
+			colors[tag.Name] = tag.Color
+		}
+	}
+	return colors
+}
+
+// parseTags returns the tags in a form value separated by commas or spaces, without repeats
+func parseTags(value string) []string {
+	tags := []string{}
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		tag := parseTag(field)
+		if tag != "" && !slices.ContainsFunc(tags, func(t string) bool { return normalizeTag(t) == normalizeTag(tag) }) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// checkTagRename returns a message for the user when a tag can't be renamed to another tag
+func checkTagRename(from, to string) string {
+	switch {
+	case !validTag(from) || !validTag(to):
+		return "Enter tags with letters, numbers, dashes and / for nested tags."
+	case from == to:
+		return "Enter a different name for the tag."
+	default:
+		return ""
+	}
+}
+
+// tagRename is a note that has a tag renamed
+type tagRename struct {
+	Note         db.Note
+	Text         string
+	ExplicitTags []string
+	Tags         []string
+}
+
+// tagRenames returns the notes that change when a tag is renamed, with their new text and tags
+func tagRenames(notes []db.Note, from, to string) []tagRename {
+	renames := []tagRename{}
+	for _, note := range notes {
+		text := renameTagInText(note.Note, from, to)
+		explicit := renameTags(note.ExplicitTags, from, to)
+		if text == note.Note && slices.Equal(explicit, note.ExplicitTags) {
+			continue
+		}
+		renames = append(renames, tagRename{
+			Note:         note,
+			Text:         text,
+			ExplicitTags: explicit,
+			Tags:         noteTags(text, explicit, note.DailyDate != nil),
+		})
+	}
+	slices.SortFunc(renames, func(a, b tagRename) int { return strings.Compare(a.Note.Title, b.Note.Title) })
+	return renames
+}
+
+// parseSearch parses a search query in the configured time location and normalizes the tags
+func parseSearch(input string) (search.Query, error) {
+	query, err := search.Parse(input, timeLocation)
+	for i, term := range query.Terms {
+		if term.Field == search.FieldTag {
+			query.Terms[i].Value = normalizeTag(parseTag(term.Value))
+		}
+	}
+	return query, err
+}
+
+// searchParams returns the search parameters for the query string of the notes list.
+// The query of the params is still usable when there's a problem parsing the q parameter.
+func searchParams(values url.Values) (db.SearchNotesParams, error) {
+	// Parse the search query
+	query, err := parseSearch(values.Get("q"))
+
+	// The tag, favorites and archived filters add terms to the query. Notes need all the
+	// selected tags, or any of them, and none of the excluded tags.
+	for _, tag := range filterTags(values["tag"]) {
+		term := search.Term{Field: search.FieldTag, Value: tag}
+		if values.Get("match") == "any" {
+			query.Any = append(query.Any, term)
+		} else {
+			query.Terms = append(query.Terms, term)
+		}
+	}
+	for _, tag := range filterTags(values["notag"]) {
+		query.Terms = append(query.Terms, search.Term{Field: search.FieldTag, Value: tag, Negate: true})
+	}
+	if values.Get("favorites") != "" {
+		query.Terms = append(query.Terms, search.Term{Field: search.FieldIs, Value: search.IsFavorite})
+	}
+	if values.Get("archived") != "" {
+		query.Terms = append(query.Terms, search.Term{Field: search.FieldIs, Value: search.IsArchived})
+	}
+
+	params := db.SearchNotesParams{
+		Query:      query,
+		Language:   searchLanguage,
+		NotebookID: values.Get("notebook"),
+		Sort:       values.Get("sort"),
+	}
+	return params, err
+}
+
+// noteSort is a sort order for the notes list and how it is described in the search form
+type noteSort struct {
+	Name  string
+	Label string
+}
+
+// noteSorts are the sort orders of the notes list
+var noteSorts = []noteSort{
+	{Name: db.SortCreated, Label: "Newest"},
+	{Name: db.SortModified, Label: "Recently modified"},
+	{Name: db.SortTitle, Label: "Title"},
+	{Name: db.SortRelevance, Label: "Best match"},
+}
+
+// pageSizes are the number of notes per page that can be chosen
+var pageSizes = []int{10, 25, 50, 100}
+
+// defaultPageSize is the number of notes per page when one isn't chosen
+const defaultPageSize = 25
+
+// pageSize returns the number of notes per page from a form value
+func pageSize(value string) int {
+	size, err := strconv.Atoi(value)
+	if err != nil || !slices.Contains(pageSizes, size) {
+		return defaultPageSize
+	}
+	return size
+}
+
+//=============================================================================
+// Saved Searches
+//=============================================================================
+
+// savedSearchQuery returns the query string of a notes list search to save, without the
+// empty parameters and with the parameters in order so the same search has the same query
+func savedSearchQuery(values url.Values) string {
+	query := url.Values{}
+	for key, list := range values {
+		// The page is not part of the search
+		if key == "after" {
+			continue
+		}
+		for _, value := range list {
+			if strings.TrimSpace(value) != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	return query.Encode()
+}
+
+// savedSearchCount is a saved search with a link to it and the number of notes it matches
+type savedSearchCount struct {
+	Search db.SavedSearch
+	URL    string
+	Count  int64
+
+	// Invalid is set when the search query can't be parsed
+	Invalid bool
+}
+
+// countSavedSearches counts the notes that match each of the saved searches
+func countSavedSearches(ctx context.Context, queries *db.Queries, searches []db.SavedSearch) ([]savedSearchCount, error) {
+	counts := make([]savedSearchCount, 0, len(searches))
+	for _, saved := range searches {
+		link := "/notes/search/?" + saved.Query
+
+		// Searches that no longer parse are shown without a count
+		values, err := url.ParseQuery(saved.Query)
+		if err != nil {
+			counts = append(counts, savedSearchCount{Search: saved, URL: link, Invalid: true})
+			continue
+		}
+		params, err := searchParams(values)
+		if err != nil {
+			counts = append(counts, savedSearchCount{Search: saved, URL: link, Invalid: true})
+			continue
+		}
+
+		count, err := queries.CountSearchNotes(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("count saved search %s: %w", saved.ID, err)
+		}
+		counts = append(counts, savedSearchCount{Search: saved, URL: link, Count: count})
+	}
+	return counts, nil
+}
+
+//=============================================================================
+// Duplicate Notes
+//=============================================================================
+
+// defaultDuplicateThreshold is the lowest trigram similarity for two notes to be listed as
+// duplicates when a threshold isn't chosen
+const defaultDuplicateThreshold = 0.6
+
+// duplicateThreshold returns the similarity threshold for the duplicates report from a form
+// value, or an error when it isn't a number between 0.1 and 1
+func duplicateThreshold(value string) (float64, error) {
+	if value == "" {
+		return defaultDuplicateThreshold, nil
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold < 0.1 || threshold > 1 {
+		return defaultDuplicateThreshold, fmt.Errorf("threshold %q is not a number between 0.1 and 1", value)
+	}
+	return threshold, nil
+}
+
+// mergeLayout is a way to combine the text of two notes and how it is described in the merge form
+type mergeLayout struct {
+	Name  string
+	Label string
+}
+
+// mergeLayouts are the ways to combine the text of merged notes
+var mergeLayouts = []mergeLayout{
+	{Name: "append", Label: "Kept note, then the other note"},
+	{Name: "prepend", Label: "Other note, then the kept note"},
+	{Name: "sections", Label: "Both notes under their titles"},
+	{Name: "keep", Label: "Only the kept note"},
+}
+
+// isMergeLayout reports whether name is one of the merge layouts
+func isMergeLayout(name string) bool {
+	return slices.ContainsFunc(mergeLayouts, func(l mergeLayout) bool { return l.Name == name })
+}
+
+// mergeNoteText returns the text of the note kept in a merge combined with the text of the
+// other note in a merge layout. Empty text is left out.
+func mergeNoteText(layout string, keep, other db.Note) string {
+	keepText, otherText := strings.TrimSpace(keep.Note), strings.TrimSpace(other.Note)
+
+	parts := []string{}
+	add := func(heading, text string) {
+		if text == "" {
+			return
+		}
+		if heading != "" {
+			text = "## " + heading + "\n\n" + text
+		}
+		parts = append(parts, text)
+	}
+	switch layout {
+	case "prepend":
+		add("", otherText)
+		add("", keepText)
+	case "sections":
+		add(keep.Title, keepText)
+		add(other.Title, otherText)
+	case "keep":
+		add("", keepText)
+	default:
+		add("", keepText)
+		add("", otherText)
+	}
+
+	separator := "\n\n---\n\n"
+	if layout == "sections" {
+		separator = "\n\n"
+	}
+	return strings.Join(parts, separator)
+}
+
+// mergeNoteTags returns the explicit tags and tags of the note kept in a merge, with the
+// merged text. Every tag of both notes is kept, so the tags that are no longer in the text
+// or added by a tagging rule become explicit tags. The reserved daily tag is only kept on
+// daily notes.
+func mergeNoteTags(keep, other db.Note, text string, rules []db.TagRule) ([]string, []string) {
+	explicit := slices.Clone(keep.ExplicitTags)
+	for _, tag := range other.ExplicitTags {
+		if len(removeTag(explicit, tag)) == len(explicit) {
+			explicit = append(explicit, tag)
+		}
+	}
+
+	daily := keep.DailyDate != nil
+	tags := ruleTags(noteTags(text, explicit, daily), rules, keep.Title, text)
+	for _, tag := range slices.Concat(keep.Tags, other.Tags) {
+		if slices.Contains(tags, tag) || (tag == dailyTag && !daily) {
+			continue
+		}
+		explicit = append(explicit, tag)
+		tags = append(tags, tag)
+	}
+	return explicit, tags
+}
+
+// errMergeConflict is returned by mergeNote when one of the notes changed during the merge
+var errMergeConflict = errors.New("note changed during the merge")
+
+// mergeNote merges the other note into the kept note. The kept note gets the combined text,
+// the tags of both notes, the earliest created time and the favorite state of either note.
+// The attachments and redirects of the other note move to the kept note, and the other
+// note is moved to the trash, or deleted when remove is "delete". Wiki links to the other
+// note are pointed at the kept note. A revision of the kept note is saved first. Call it in
+// a transaction.
+func mergeNote(ctx context.Context, queries *db.Queries, keep, other db.Note, layout, remove string) error {
+	rules, err := queries.ListTagRules(ctx)
+	if err != nil {
+		return fmt.Errorf("list tag rules: %w", err)
+	}
+	if err := snapshotNote(ctx, queries, keep.ID); err != nil {
+		return err
+	}
+
+	// Links to the other note by title only reach it while it is the note with the title
+	resolved, err := queries.ResolveWikiLink(ctx, other.Title)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("resolve wiki link: %w", err)
+	}
+	byTitle := resolved.ID == other.ID
+
+	text := retargetWikiLinks(mergeNoteText(layout, keep, other), keep, other, byTitle)
+	explicit, tags := mergeNoteTags(keep, other, text, rules)
+	params := db.UpdateNoteParams{
+		ID:           keep.ID,
+		Title:        keep.Title,
+		Note:         text,
+		Archive:      keep.Archive,
+		Favorite:     keep.Favorite || other.Favorite,
+		CreatedAt:    keep.CreatedAt,
+		Tags:         tags,
+		NotebookID:   keep.NotebookID,
+		IsTemplate:   keep.IsTemplate,
+		RemindAt:     keep.RemindAt,
+		ModifiedAt:   keep.ModifiedAt,
+		ExplicitTags: explicit,
+	}
+	if other.CreatedAt.Before(keep.CreatedAt) {
+		params.CreatedAt = other.CreatedAt
+	}
+	if _, err := queries.UpdateNote(ctx, params); errors.Is(err, pgx.ErrNoRows) {
+		return errMergeConflict
+	} else if err != nil {
+		return fmt.Errorf("update note: %w", err)
+	}
+	if err := saveNoteLinks(ctx, queries, keep.ID, text); err != nil {
+		return err
+	}
+
+	// Point the wiki links in other notes at the kept note
+	backlinks, err := queries.ListBacklinks(ctx, db.ListBacklinksParams{ID: other.ID, Title: other.Title})
+	if err != nil {
+		return fmt.Errorf("list backlinks: %w", err)
+	}
+	for _, note := range backlinks {
+		linked := retargetWikiLinks(note.Note, keep, other, byTitle)
+		if note.ID == keep.ID || linked == note.Note {
+			continue
+		}
+		if err := updateNoteText(ctx, queries, note, linked, note.ExplicitTags); err != nil {
+			return err
+		}
+		if err := saveNoteLinks(ctx, queries, note.ID, linked); err != nil {
+			return err
+		}
+	}
+
+	// Move the attachments so the links to them in the merged text keep working
+	if err := queries.MoveNoteAttachments(ctx, db.MoveNoteAttachmentsParams{ToID: keep.ID, FromID: other.ID}); err != nil {
+		return fmt.Errorf("move note attachments: %w", err)
+	}
+
+	// Redirect the other note, and the notes merged into it before, to the kept note
+	if err := queries.MoveNoteRedirects(ctx, db.MoveNoteRedirectsParams{ToID: keep.ID, FromID: other.ID}); err != nil {
+		return fmt.Errorf("move note redirects: %w", err)
+	}
+	if err := queries.CreateNoteRedirect(ctx, db.CreateNoteRedirectParams{OldID: other.ID, NoteID: keep.ID}); err != nil {
+		return fmt.Errorf("create note redirect: %w", err)
+	}
+
+	if remove == "delete" {
+		if err := queries.DeleteNote(ctx, other.ID); err != nil {
+			return fmt.Errorf("delete note: %w", err)
+		}
+		return nil
+	}
+	count, err := queries.TrashNote(ctx, other.ID)
+	if err != nil {
+		return fmt.Errorf("trash note: %w", err)
+	} else if count == 0 {
+		return errMergeConflict
+	}
+	return nil
+}
+
+// retargetWikiLinks points the wiki links to the other note in the text at the kept note.
+// Links by title are only changed when byTitle is true. Links keep their label, or the
+// title they linked by.
+func retargetWikiLinks(text string, keep, other db.Note, byTitle bool) string {
+	return wikilink.Rewrite(text, func(link *wikilink.Node) (string, bool) {
+		switch {
+		case link.Target == other.ID:
+			return wikilink.Link(keep.ID, link.Label), true
+		case byTitle && strings.EqualFold(link.Target, other.Title):
+			label := link.Label
+			if label == "" {
+				label = link.Target
+			}
+			return wikilink.Link(keep.ID, label), true
+		}
+		return "", false
+	})
+}
+
+// noteNotFound responds to a request for a missing note. A note that was merged into another
+// note redirects to the same page of the other note. Form posts are redirected with their
+// method and body, so they act on the other note.
+func noteNotFound(w http.ResponseWriter, r *http.Request, id string, logger *slog.Logger, showTrace bool, queries *db.Queries) {
+	noteID, err := queries.GetNoteRedirect(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) || noteID == id {
+		clientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		serverError(w, r, err, logger, showTrace)
+		return
+	}
+
+	path := strings.Replace(r.URL.Path, "/note/"+id+"/", "/note/"+noteID+"/", 1)
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Redirect(w, r, path, http.StatusPermanentRedirect)
+		return
+	}
+	http.Redirect(w, r, path, http.StatusMovedPermanently)
+}
+
+//=============================================================================
+// Tag Rules
+//=============================================================================
+
+// tagRuleKind is a kind of automatic tagging rule and how it is described on the rules page
+type tagRuleKind struct {
+	Name  string
+	Label string
+}
+
+// tagRuleKinds are the kinds of automatic tagging rules
+var tagRuleKinds = []tagRuleKind{
+	{Name: "title_prefix", Label: "Title starts with"},
+	{Name: "title_contains", Label: "Title contains"},
+	{Name: "note_contains", Label: "Note contains"},
+	{Name: "url_domain", Label: "Note links to the domain"},
+}
+
+// urlRX matches the http and https links in note text
+var urlRX = regexp.MustCompile(`https?://[^\s<>()\[\]"']+`)
+
+// tagRuleMatches reports whether a note with the title and text matches a tagging rule.
+// Matches ignore case. A url_domain rule also matches links to subdomains of the domain.
+func tagRuleMatches(rule db.TagRule, title, text string) bool {
+	value := strings.ToLower(rule.Value)
+	switch rule.Kind {
+	case "title_prefix":
+		return strings.HasPrefix(strings.ToLower(title), value)
+	case "title_contains":
+		return strings.Contains(strings.ToLower(title), value)
+	case "note_contains":
+		return strings.Contains(strings.ToLower(text), value)
+	case "url_domain":
+		for _, link := range urlRX.FindAllString(text, -1) {
+			u, err := url.Parse(link)
+			if err != nil {
+				continue
+			}
+			if host := strings.ToLower(u.Hostname()); host == value || strings.HasSuffix(host, "."+value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ruleTags adds the tags of the tagging rules that match a note to the note's tags
+func ruleTags(tags []string, rules []db.TagRule, title, text string) []string {
+	for _, rule := range rules {
+		if tag := normalizeTag(rule.Tag); !slices.Contains(tags, tag) && tagRuleMatches(rule, title, text) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseTagRule returns the tagging rule in the form values with a cleaned up value, and the
+// problems with the rule by field
+func parseTagRule(form url.Values) (db.CreateTagRuleParams, map[string]string) {
+	params := db.CreateTagRuleParams{
+		Kind:  form.Get("kind"),
+		Value: strings.TrimSpace(form.Get("value")),
+		Tag:   parseTag(form.Get("tag")),
+	}
+
+	// Keep only the host of a pasted link for url_domain rules
+	if params.Kind == "url_domain" {
+		if u, err := url.Parse(params.Value); err == nil && u.Host != "" {
+			params.Value = u.Hostname()
+		}
+		params.Value = strings.ToLower(strings.Trim(params.Value, "./"))
+	}
+
+	v := validator.Validator{}
+	v.Check("Kind", slices.ContainsFunc(tagRuleKinds, func(k tagRuleKind) bool { return k.Name == params.Kind }), "Choose a kind of rule.")
+	v.Check("Value", validator.NotBlank(params.Value), "The rule needs a value to match.")
+	v.Check("Tag", validTag(params.Tag), "Tags can only have letters, numbers, dashes and / for nested tags.")
+	return params, v.Errors
+}
+
+// tagRuleResult is a tagging rule with the notes it matches that don't have its tag yet
+type tagRuleResult struct {
+	Rule    db.TagRule
+	Matches int
+	Notes   []db.Note
+}
+
+// tagRuleDryRun returns the notes each tagging rule would add its tag to
+func tagRuleDryRun(rules []db.TagRule, notes []db.Note) []tagRuleResult {
+	results := []tagRuleResult{}
+	for _, rule := range rules {
+		result := tagRuleResult{Rule: rule, Notes: []db.Note{}}
+		for _, note := range notes {
+			if !tagRuleMatches(rule, note.Title, note.Note) {
+				continue
+			}
+			result.Matches++
+			if !slices.Contains(note.Tags, normalizeTag(rule.Tag)) {
+				result.Notes = append(result.Notes, note)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// dailyNoteOptions configures the title and starting body for new daily notes
+type dailyNoteOptions struct {
+	TitleFormat string
+	Body        string
+}
+
+// createDailyNote creates the daily note for a day in the configured time location.
+// If another request created the note first, that note is returned.
+func createDailyNote(ctx context.Context, queries *db.Queries, options dailyNoteOptions, day time.Time) (db.Note, error) {
+	id, err := db.GenerateID("n")
+	if err != nil {
+		return db.Note{}, err
+	}
+
+	title := day.Format(options.TitleFormat)
+	body := expandTemplate(options.Body, title, day)
+	date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Tag the note like any other new note
+	rules, err := queries.ListTagRules(ctx)
+	if err != nil {
+		return db.Note{}, fmt.Errorf("list tag rules: %w", err)
+	}
+
+	params := db.CreateDailyNoteParams{
+		ID:        id,
+		Title:     title,
+		Note:      body,
+		Tags:      ruleTags(noteTags(body, nil, true), rules, title, body),
+		DailyDate: date,
+	}
+	note, err := queries.CreateDailyNote(ctx, params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return queries.GetDailyNote(ctx, date)
+	} else if err != nil {
+		return db.Note{}, fmt.Errorf("create daily note: %w", err)
+	}
+	return note, nil
+}
+
+// expandTemplate replaces the {{date}}, {{time}}, {{weekday}} and {{title}} placeholders in
+// template text. The date and time placeholders use the time t in the configured time location.
+func expandTemplate(text, title string, t time.Time) string {
+	t = t.In(timeLocation)
+	r := strings.NewReplacer(
+		"{{date}}", t.Format("2006-01-02"),
+		"{{time}}", t.Format("15:04"),
+		"{{weekday}}", t.Weekday().String(),
+		"{{title}}", title,
+	)
+	return r.Replace(text)
+}
+
+// saveNoteLinks replaces the stored [[wiki links]] for a note with the links in the note text
+func saveNoteLinks(ctx context.Context, queries *db.Queries, noteID, text string) error {
+	if err := queries.DeleteNoteLinks(ctx, noteID); err != nil {
+		return fmt.Errorf("delete note links: %w", err)
+	}
+
+	params := db.CreateNoteLinksParams{
+		SourceID: noteID,
+		Targets:  wikilink.Targets(text),
+	}
+	if err := queries.CreateNoteLinks(ctx, params); err != nil {
+		return fmt.Errorf("create note links: %w", err)
+	}
+	return nil
+}
+
+// refreshAllNoteLinks saves the wiki links from the text of every note again and returns
+// the number of notes
+func refreshAllNoteLinks(ctx context.Context, queries *db.Queries) (int, error) {
+	notes, err := queries.ListAllNotes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, note := range notes {
+		if err := saveNoteLinks(ctx, queries, note.ID, note.Note); err != nil {
+			return 0, err
+		}
+	}
+	return len(notes), nil
+}
+
+// wikiLinkResolver returns a wikilink.Resolver that looks up notes by ID or title with the
+// request context. Each target is only looked up once per request, so a page with many
+// notes doesn't query for the same links again.
+func wikiLinkResolver(ctx context.Context, logger *slog.Logger, queries *db.Queries) wikilink.Resolver {
+	resolved := map[string]*wikilink.Note{}
+	return func(targets []string) map[string]wikilink.Note {
+		missing := []string{}
+		for _, target := range targets {
+			if _, ok := resolved[target]; !ok {
+				missing = append(missing, target)
+			}
+		}
+
+		if len(missing) > 0 {
+			rows, err := queries.ResolveWikiLinks(ctx, missing)
+			if err != nil {
+				// The links are shown as missing notes
+				logger.Error("resolve wiki links error", "targets", missing, "error", err)
+				return nil
+			}
+			for _, target := range missing {
+				resolved[target] = nil
+			}
+			for _, row := range rows {
+				resolved[row.Target] = &wikilink.Note{ID: row.ID, Title: row.Title}
+			}
+		}
+
+		notes := map[string]wikilink.Note{}
+		for _, target := range targets {
+			if note := resolved[target]; note != nil {
+				notes[target] = *note
+			}
+		}
+		return notes
+	}
+}
+
+//=============================================================================
+// Notebook Helpers
+//=============================================================================
+
+// notebookNode is a notebook with its child notebooks
+type notebookNode struct {
+	db.ListNotebooksRow
+	Depth    int
+	Children []*notebookNode
+}
+
+// ParentNotebookID returns the ID of the parent notebook or "" for a top level notebook
+func (n *notebookNode) ParentNotebookID() string {
+	if n.ParentID == nil {
+		return ""
+	}
+	return *n.ParentID
+}
+
+// notebookTree arranges notebooks into a tree and returns the top level notebooks.
+// The notebooks keep their order within each level of the tree.
+func notebookTree(notebooks []db.ListNotebooksRow) []*notebookNode {
+	nodes := make(map[string]*notebookNode, len(notebooks))
+	for _, nb := range notebooks {
+		nodes[nb.ID] = &notebookNode{ListNotebooksRow: nb}
+	}
+
+	roots := []*notebookNode{}
+	for _, nb := range notebooks {
+		node := nodes[nb.ID]
+		if nb.ParentID != nil {
+			if parent, ok := nodes[*nb.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	// Set the depth of each notebook in the tree
+	var setDepth func(nodes []*notebookNode, depth int)
+	setDepth = func(nodes []*notebookNode, depth int) {
+		for _, node := range nodes {
+			node.Depth = depth
+			setDepth(node.Children, depth+1)
+		}
+	}
+	setDepth(roots, 0)
+
+	return roots
+}
+
+// flattenNotebookTree returns all the notebooks in a tree in display order
+func flattenNotebookTree(tree []*notebookNode) []*notebookNode {
+	flat := []*notebookNode{}
+	for _, node := range tree {
+		flat = append(flat, node)
+		flat = append(flat, flattenNotebookTree(node.Children)...)
+	}
+	return flat
+}
+
+// notebookPath returns a notebook and its parent notebooks, starting with the top level notebook
+func notebookPath(notebooks []db.ListNotebooksRow, id string) []db.ListNotebooksRow {
+	byID := make(map[string]db.ListNotebooksRow, len(notebooks))
+	for _, nb := range notebooks {
+		byID[nb.ID] = nb
+	}
+
+	path := []db.ListNotebooksRow{}
+	for len(path) <= len(notebooks) {
+		nb, ok := byID[id]
+		if !ok {
+			break
+		}
+		path = append([]db.ListNotebooksRow{nb}, path...)
+		if nb.ParentID == nil {
+			break
+		}
+		id = *nb.ParentID
+	}
+	return path
+}
+
+// notebookContains returns true when the notebook id is the notebook ancestorID or is inside of it
+func notebookContains(notebooks []db.ListNotebooksRow, ancestorID, id string) bool {
+	for _, nb := range notebookPath(notebooks, id) {
+		if nb.ID == ancestorID {
+			return true
+		}
+	}
+	return false
+}
+
+//=============================================================================
+// Attachment Helpers
+//=============================================================================
+
+// maxAttachmentSize is the largest file that can be attached to a note
+const maxAttachmentSize = 20 << 20
+
+// maxNoteFormSize is the largest note form, with all of its attachments, that can be posted
+const maxNoteFormSize = 100 << 20
+
+// attachmentUpload is a file uploaded with the note form that hasn't been saved yet
+type attachmentUpload struct {
+	ID          string
+	Filename    string
+	ContentType string
+	File        *multipart.FileHeader
+}
+
+// newAttachmentUpload creates an ID for an uploaded file and detects its content type
+func newAttachmentUpload(fh *multipart.FileHeader) (attachmentUpload, error) {
+	id, err := db.GenerateID("a")
+	if err != nil {
+		return attachmentUpload{}, err
+	}
+
+	// Read the start of the file to detect the content type
+	f, err := fh.Open()
+	if err != nil {
+		return attachmentUpload{}, fmt.Errorf("open upload: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return attachmentUpload{}, fmt.Errorf("read upload: %w", err)
+	}
+
+	// Remove any path and characters that would break a markdown link from the file name
+	filename := strings.Map(func(r rune) rune {
+		if strings.ContainsRune("[]()<>\"\\", r) || r < ' ' {
+			return -1
+		}
+		return r
+	}, filepath.Base(fh.Filename))
+	if filename == "" || filename == "." || filename == "/" {
+		filename = id
+	}
+
+	return attachmentUpload{
+		ID:          id,
+		Filename:    filename,
+		ContentType: http.DetectContentType(head[:n]),
+		File:        fh,
+	}, nil
+}
+
+// attachmentMarkdown returns a markdown link to an attachment. Images are embedded in the note.
+func attachmentMarkdown(id, filename, contentType string) string {
+	link := fmt.Sprintf("[%s](/attachment/%s/)", filename, id)
+	if strings.HasPrefix(contentType, "image/") {
+		return "!" + link
+	}
+	return link
+}
+
+// createAttachment adds the record for an uploaded file to a note. The file is stored with
+// storeAttachment after the record is committed.
+func createAttachment(ctx context.Context, queries *db.Queries, noteID string, upload attachmentUpload) error {
+	params := db.CreateAttachmentParams{
+		ID:          upload.ID,
+		NoteID:      noteID,
+		Filename:    upload.Filename,
+		ContentType: upload.ContentType,
+		Size:        upload.File.Size,
+	}
+	if _, err := queries.CreateAttachment(ctx, params); err != nil {
+		return fmt.Errorf("create attachment: %w", err)
+	}
+	return nil
+}
+
+// storeAttachment saves an uploaded file to the attachment store. The attachment record is
+// removed if the file can't be stored.
+func storeAttachment(ctx context.Context, queries *db.Queries, attachments storage.Store, upload attachmentUpload) error {
+	f, err := upload.File.Open()
+	if err == nil {
+		defer f.Close()
+		err = attachments.Save(ctx, upload.ID, f)
+	}
+
+	// Remove the attachment record if the file can't be stored
+	if err != nil {
+		if err := queries.DeleteAttachment(ctx, upload.ID); err != nil {
+			return fmt.Errorf("delete attachment: %w", err)
+		}
+		return fmt.Errorf("save attachment: %w", err)
+	}
+	return nil
+}
+
+// deleteAttachmentFiles removes the files for deleted attachments from the attachment store
+func deleteAttachmentFiles(ctx context.Context, logger *slog.Logger, attachments storage.Store, ids []string) {
+	for _, id := range ids {
+		if err := attachments.Delete(ctx, id); err != nil {
+			logger.Error("delete attachment file error", "id", id, "error", err)
+		}
+	}
+}
+
+//=============================================================================
+// Task Helpers
+//=============================================================================
+
+// taskGroup is the open checklist items in a note
+type taskGroup struct {
+	Note  db.Note
+	Tasks []tasklist.Item
+	Due   *time.Time
+}
+
+// openTasks collects the open checklist items in the notes, skipping template notes.
+// Tasks with a due date come first, sorted by the date, and the notes are sorted
+// by their earliest due date.
+func openTasks(notes []db.Note) []taskGroup {
+	groups := []taskGroup{}
+	for _, note := range notes {
+		if note.IsTemplate {
+			continue
+		}
+
+		group := taskGroup{Note: note}
+		for _, item := range tasklist.Items(note.Note) {
+			if !item.Checked {
+				group.Tasks = append(group.Tasks, item)
+			}
+		}
+		if len(group.Tasks) == 0 {
+			continue
+		}
+
+		slices.SortStableFunc(group.Tasks, func(a, b tasklist.Item) int {
+			return compareDue(a.Due, b.Due)
+		})
+		group.Due = group.Tasks[0].Due
+		groups = append(groups, group)
+	}
+
+	slices.SortStableFunc(groups, func(a, b taskGroup) int {
+		return compareDue(a.Due, b.Due)
+	})
+	return groups
+}
+
+// compareDue orders due dates from earliest to latest, with missing dates last
+func compareDue(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
+
 //=============================================================================
 // Flash Message functions
 //=============================================================================
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"net/url"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/sglmr/go-notes/db"
+	"github.com/sglmr/go-notes/internal/assert"
 )
 
 func TestExtractTags(t *testing.T) {
@@ -75,9 +80,19 @@ func TestExtractTags(t *testing.T) {
 			expected: []string{"with-hyphen"},
 		},
 		{
-			name:     "Hashtag with uppercase letters - should not match",
-			input:    "This is #HashTag",
-			expected: []string{},
+			name:     "Hashtag with uppercase letters - should be case folded",
+			input:    "This is #HashTag and #hashtag",
+			expected: []string{"hashtag"},
+		},
+		{
+			name:     "Unicode hashtags",
+			input:    "#Café au lait, #日本語 and #Straße/München",
+			expected: []string{"café", "日本語", "strasse/münchen"},
+		},
+		{
+			name:     "Decomposed accents are normalized",
+			input:    "#cafe\u0301 #café",
+			expected: []string{"café"},
 		},
 		{
 			name:     "Markdown link - should be excluded",
@@ -94,6 +109,21 @@ func TestExtractTags(t *testing.T) {
 			input:    "This is #a",
 			expected: []string{},
 		},
+		{
+			name:     "Inline code - should be excluded",
+			input:    "Use `#include` and ``a #pragma`` with #cpp",
+			expected: []string{"cpp"},
+		},
+		{
+			name:     "Fenced code block - should be excluded",
+			input:    "#before\n\n```sh\n# comment\necho #code\n```\n\n~~~\n#tilde\n~~~\n#after",
+			expected: []string{"before", "after"},
+		},
+		{
+			name:     "Nested hashtags",
+			input:    "#project/alpha and #project/beta-2/notes but not #project/ or #project/123",
+			expected: []string{"project/alpha", "project/beta-2/notes", "project"},
+		},
 		{
 			name:     "Various valid and invalid hashtags mixed",
 			input:    "Valid: #valid #valid-one #v123 Invalid: #123 #A #",
@@ -114,3 +144,548 @@ func TestExtractTags(t *testing.T) {
 		})
 	}
 }
+
+func TestNotebookTree(t *testing.T) {
+	t.Parallel()
+
+	ptr := func(s string) *string { return &s }
+	notebooks := []db.ListNotebooksRow{
+		{ID: "nb_1", Name: "Home"},
+		{ID: "nb_2", Name: "Recipes", ParentID: ptr("nb_1")},
+		{ID: "nb_3", Name: "Work"},
+		{ID: "nb_4", Name: "Desserts", ParentID: ptr("nb_2")},
+		{ID: "nb_5", Name: "Orphan", ParentID: ptr("nb_missing")},
+	}
+
+	// Check the top level notebooks
+	tree := notebookTree(notebooks)
+	names := []string{}
+	for _, node := range tree {
+		names = append(names, node.Name)
+	}
+	assert.EqualSlices(t, []string{"Home", "Work", "Orphan"}, names)
+
+	// Check the display order and depth of all the notebooks
+	names = []string{}
+	depths := []int{}
+	for _, node := range flattenNotebookTree(tree) {
+		names = append(names, node.Name)
+		depths = append(depths, node.Depth)
+	}
+	assert.EqualSlices(t, []string{"Home", "Recipes", "Desserts", "Work", "Orphan"}, names)
+	assert.EqualSlices(t, []int{0, 1, 2, 0, 0}, depths)
+
+	// Check the path to a notebook
+	names = []string{}
+	for _, nb := range notebookPath(notebooks, "nb_4") {
+		names = append(names, nb.Name)
+	}
+	assert.EqualSlices(t, []string{"Home", "Recipes", "Desserts"}, names)
+	assert.Equal(t, 0, len(notebookPath(notebooks, "nb_missing")))
+
+	// Check which notebooks are inside other notebooks
+	assert.Equal(t, true, notebookContains(notebooks, "nb_1", "nb_4"))
+	assert.Equal(t, true, notebookContains(notebooks, "nb_2", "nb_2"))
+	assert.Equal(t, false, notebookContains(notebooks, "nb_4", "nb_1"))
+	assert.Equal(t, false, notebookContains(notebooks, "nb_3", "nb_4"))
+}
+
+func TestExpandTemplate(t *testing.T) {
+	var err error
+	timeLocation, err = time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2025-03-04 02:30 UTC is the evening before in Los Angeles
+	now := time.Date(2025, 3, 4, 2, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		title string
+		want  string
+	}{
+		{
+			name:  "No placeholders",
+			input: "Just a #note",
+			want:  "Just a #note",
+		},
+		{
+			name:  "Date and time in the time location",
+			input: "{{weekday}} {{date}} at {{time}}",
+			want:  "Monday 2025-03-03 at 18:30",
+		},
+		{
+			name:  "Title",
+			input: "# {{title}}\n\n{{title}} notes",
+			title: "Standup",
+			want:  "# Standup\n\nStandup notes",
+		},
+		{
+			name:  "Unknown placeholders are left alone",
+			input: "{{month}} {{ date }}",
+			want:  "{{month}} {{ date }}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expandTemplate(tt.input, tt.title, now))
+		})
+	}
+}
+
+func TestNoteTags(t *testing.T) {
+	t.Parallel()
+
+	assert.EqualSlices(t, []string{"work"}, noteTags("A #work note", nil, false))
+	assert.EqualSlices(t, []string{"work", "daily"}, noteTags("A #work note", nil, true))
+	assert.EqualSlices(t, []string{"daily", "work"}, noteTags("A #daily #work note", nil, true))
+	assert.EqualSlices(t, []string{"work", "todo", "home"}, noteTags("A #work note", []string{"ToDo", "Work", "home"}, false))
+
+	assert.EqualSlices(t, []string{"ToDo", "home", "café"}, parseTags(" #ToDo, home  todo,,café "))
+	assert.EqualSlices(t, []string{}, parseTags(" , "))
+	assert.EqualSlices(t, []string{"jobs/alpha", "home"}, renameTags([]string{"Work/alpha", "home", "jobs/Alpha"}, "work", "jobs"))
+	assert.EqualSlices(t, []string{"home"}, removeTag([]string{"ToDo", "home"}, "todo"))
+
+	labels := noteTagLabels(db.Note{Note: "#Work", ExplicitTags: []string{"ToDo", "work"}}, nil)
+	assert.Equal(t, "Work", labels["work"])
+	assert.Equal(t, "ToDo", labels["todo"])
+}
+
+func TestOpenTasks(t *testing.T) {
+	t.Parallel()
+
+	notes := []db.Note{
+		{ID: "n_1", Note: "- [ ] no date\n- [x] done @due(2026-01-01)"},
+		{ID: "n_2", Note: "- [ ] later @due(2026-03-01)\n- [ ] sooner @due(2026-02-01)\n- [ ] whenever"},
+		{ID: "n_3", Note: "Nothing to do"},
+		{ID: "n_4", Note: "- [ ] template task @due(2026-01-01)", IsTemplate: true},
+		{ID: "n_5", Note: "- [ ] soonest @due(2026-01-15)"},
+	}
+
+	groups := openTasks(notes)
+	ids := []string{}
+	for _, group := range groups {
+		ids = append(ids, group.Note.ID)
+	}
+	assert.EqualSlices(t, []string{"n_5", "n_2", "n_1"}, ids)
+
+	texts := []string{}
+	for _, task := range groups[1].Tasks {
+		texts = append(texts, task.Text)
+	}
+	assert.EqualSlices(t, []string{"sooner", "later", "whenever"}, texts)
+	assert.Equal(t, "2026-02-01", groups[1].Due.Format("2006-01-02"))
+	assert.Equal(t, true, groups[2].Due == nil)
+}
+
+func TestLocalRedirectURL(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "/tasks/", localRedirectURL("/tasks/", "/"))
+	assert.Equal(t, "/", localRedirectURL("", "/"))
+	assert.Equal(t, "/", localRedirectURL("https://example.com/", "/"))
+	assert.Equal(t, "/", localRedirectURL("//example.com/", "/"))
+	assert.Equal(t, "/", localRedirectURL(`/\example.com/`, "/"))
+}
+
+func TestAddRemoveTag(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, true, validTag("work"))
+	assert.Equal(t, true, validTag("to-do-2"))
+	assert.Equal(t, false, validTag("a"))
+	assert.Equal(t, false, validTag("2026"))
+	assert.Equal(t, false, validTag("two words"))
+
+	assert.Equal(t, "A note\n\n#work", addTagToText("A note", "work"))
+	assert.Equal(t, "A #work note", addTagToText("A #work note", "work"))
+
+	assert.Equal(t, "A note", removeTagFromText("A #work note", "work"))
+	assert.Equal(t, "A note #work-log", removeTagFromText("A #work note #work-log", "work"))
+	assert.Equal(t, "A note\n", removeTagFromText("A note\n#work", "work"))
+	assert.Equal(t, "A note", removeTagFromText("A note", "work"))
+}
+
+func TestRenameTagInText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "Rename every hashtag",
+			input: "#meeting notes\n\nAnother #meeting, #meetings and #meeting-notes",
+			want:  "#meetings notes\n\nAnother #meetings, #meetings and #meeting-notes",
+		},
+		{
+			name:  "Nested tags are renamed with their parent",
+			input: "#meeting/standup and #meeting/retro/q1",
+			want:  "#meetings/standup and #meetings/retro/q1",
+		},
+		{
+			name:  "Mixed case tags are renamed",
+			input: "#Meeting and #MEETING/Standup",
+			want:  "#meetings and #meetings/Standup",
+		},
+		{
+			name:  "Links are not changed",
+			input: "[link](#meeting) <a href=\"#meeting\">link</a>",
+			want:  "[link](#meeting) <a href=\"#meeting\">link</a>",
+		},
+		{
+			name:  "Code is not changed",
+			input: "`#meeting`\n```\n#meeting\n```\n#meeting",
+			want:  "`#meeting`\n```\n#meeting\n```\n#meetings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, renameTagInText(tt.input, "meeting", "meetings"))
+		})
+	}
+}
+
+func TestTagRenames(t *testing.T) {
+	t.Parallel()
+
+	notes := []db.Note{
+		{ID: "n_1", Title: "Standup", Note: "A #meeting about #work"},
+		{ID: "n_2", Title: "Code", Note: "`#meeting`"},
+		{ID: "n_3", Title: "Retro", Note: "#meeting #meetings"},
+		{ID: "n_4", Title: "Agenda", Note: "No hashtags", ExplicitTags: []string{"Meeting"}},
+	}
+
+	renames := tagRenames(notes, "meeting", "meetings")
+	assert.Equal(t, 3, len(renames))
+	assert.Equal(t, "n_4", renames[0].Note.ID)
+	assert.EqualSlices(t, []string{"meetings"}, renames[0].ExplicitTags)
+	renames = renames[1:]
+	assert.Equal(t, "n_3", renames[0].Note.ID)
+	assert.EqualSlices(t, []string{"meetings"}, renames[0].Tags)
+	assert.Equal(t, "A #meetings about #work", renames[1].Text)
+	assert.EqualSlices(t, []string{"meetings", "work"}, renames[1].Tags)
+
+	assert.Equal(t, "", checkTagRename("meeting", "meetings"))
+	assert.NotEqual(t, "", checkTagRename("meeting", "meeting"))
+	assert.NotEqual(t, "", checkTagRename("meeting", "2026"))
+}
+
+func TestTagTree(t *testing.T) {
+	t.Parallel()
+
+	tags := []db.TagCount{
+		{TagName: "work", NoteCount: 1, Total: 1},
+		{TagName: "project/beta", NoteCount: 2, Total: 2},
+		{TagName: "project/alpha/docs", NoteCount: 1, Total: 1},
+		{TagName: "project", NoteCount: 1, Total: 5},
+		{TagName: "project/alpha", NoteCount: 3, Total: 3},
+	}
+
+	tree := tagTree(tags)
+	assert.Equal(t, 2, len(tree))
+
+	project := tree[0]
+	assert.Equal(t, "project", project.Name)
+	assert.Equal(t, int64(1), project.NoteCount)
+	assert.Equal(t, int64(5), project.Total)
+	assert.Equal(t, 2, len(project.Children))
+
+	alpha := project.Children[0]
+	assert.Equal(t, "project/alpha", alpha.Name)
+	assert.Equal(t, "alpha", alpha.Label)
+	assert.Equal(t, int64(3), alpha.Total)
+	assert.Equal(t, "docs", alpha.Children[0].Label)
+
+	selectTags(tree, []string{"project/alpha"}, []string{"work"})
+	assert.Equal(t, true, project.Open)
+	assert.Equal(t, false, project.Selected)
+	assert.Equal(t, true, alpha.Open)
+	assert.Equal(t, true, alpha.Selected)
+	assert.Equal(t, false, alpha.Excluded)
+	assert.Equal(t, true, tree[1].Open)
+	assert.Equal(t, true, tree[1].Excluded)
+
+	assert.Equal(t, true, validTag("project/alpha"))
+	assert.Equal(t, false, validTag("project/"))
+	assert.Equal(t, false, validTag("/alpha"))
+}
+
+func TestFacetTags(t *testing.T) {
+	t.Parallel()
+
+	all := []db.TagSummary{
+		{TagName: "cooking", NoteCount: 4},
+		{TagName: "recipe", NoteCount: 2},
+		{TagName: "work", NoteCount: 3},
+	}
+	matching := []db.TagCount{
+		{TagName: "recipe", NoteCount: 2, Total: 2},
+		{TagName: "cooking", NoteCount: 1, Total: 1},
+	}
+
+	assert.EqualSlices(t, []db.TagCount{
+		{TagName: "cooking", NoteCount: 1, Total: 1},
+		{TagName: "recipe", NoteCount: 2, Total: 2},
+		{TagName: "work", NoteCount: 0, Total: 0},
+	}, facetTags(all, matching))
+
+	assert.EqualSlices(t, []string{"work", "recipe"}, filterTags([]string{"#Work", "", "recipe", "work"}))
+}
+
+func TestTagChips(t *testing.T) {
+	t.Parallel()
+
+	u, err := url.Parse("/notes/search/?q=bread&tag=recipe&tag=%23Baking&notag=work&match=any")
+	assert.NoError(t, err)
+
+	chips := tagChips(u, []string{"recipe", "baking"}, []string{"work"})
+	assert.EqualSlices(t, []tagChip{
+		{Name: "recipe", RemoveURL: "/notes/search/?match=any&notag=work&q=bread&tag=%23Baking"},
+		{Name: "baking", RemoveURL: "/notes/search/?match=any&notag=work&q=bread&tag=recipe"},
+		{Name: "work", Excluded: true, RemoveURL: "/notes/search/?match=any&q=bread&tag=recipe&tag=%23Baking"},
+	}, chips)
+}
+
+func TestTagLabels(t *testing.T) {
+	t.Parallel()
+
+	labels := tagLabels("#ToDo and #todo #Café", nil)
+	assert.Equal(t, "ToDo", labels["todo"])
+	assert.Equal(t, "Café", labels["café"])
+
+	labels = tagLabels("#TODO #Work", labels)
+	assert.Equal(t, "ToDo", labels["todo"])
+	assert.Equal(t, "Work", labels["work"])
+
+	assert.Equal(t, "straße", parseTag(" #straße "))
+	assert.Equal(t, "strasse", normalizeTag("Straße"))
+	assert.Equal(t, true, validTag("日本"))
+	assert.Equal(t, false, validTag("日"))
+	assert.Equal(t, "Note\n\n#ToDo", addTagToText("Note", "ToDo"))
+	assert.Equal(t, "Note #todo", addTagToText("Note #todo", "ToDo"))
+	assert.Equal(t, "Note", removeTagFromText("Note #ToDo", "todo"))
+}
+
+func TestTagColors(t *testing.T) {
+	t.Parallel()
+
+	colors := tagColors([]db.Tag{
+		{Name: "work", Color: "#3b82f6"},
+		{Name: "home", Description: "Chores"},
+	})
+	assert.Equal(t, 1, len(colors))
+	assert.Equal(t, "#3b82f6", colors["work"])
+
+	assert.Equal(t, true, colorRX.MatchString("#3b82f6"))
+	assert.Equal(t, false, colorRX.MatchString("#3B82F6"))
+	assert.Equal(t, false, colorRX.MatchString("red"))
+	assert.Equal(t, false, colorRX.MatchString(`#3b82f6;background:url(x)`))
+}
+
+func TestRuleTags(t *testing.T) {
+	t.Parallel()
+
+	rules := []db.TagRule{
+		{Kind: "title_prefix", Value: "Standup", Tag: "standup"},
+		{Kind: "url_domain", Value: "jira.example.com", Tag: "Jira"},
+		{Kind: "note_contains", Value: "invoice", Tag: "finance"},
+	}
+
+	tests := []struct {
+		name  string
+		title string
+		text  string
+		want  []string
+	}{
+		{
+			name:  "Title prefix ignores case",
+			title: "standup 2026-10-17",
+			text:  "Notes #work",
+			want:  []string{"work", "standup"},
+		},
+		{
+			name:  "Title prefix only matches the start",
+			title: "Daily Standup",
+			text:  "Notes",
+			want:  []string{},
+		},
+		{
+			name:  "Link to the domain or a subdomain",
+			title: "Bug",
+			text:  "See https://jira.example.com/browse/X-1 and <https://eu.jira.example.com/x>",
+			want:  []string{"jira"},
+		},
+		{
+			name:  "Link to a different domain",
+			title: "Bug",
+			text:  "See https://notjira.example.com/ and https://jira.example.com.evil.test/",
+			want:  []string{},
+		},
+		{
+			name:  "Note contains",
+			title: "Bills",
+			text:  "Pay the INVOICE #finance",
+			want:  []string{"finance"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.EqualSlices(t, tt.want, ruleTags(extractTags(tt.text), rules, tt.title, tt.text))
+		})
+	}
+
+	// The dry run only lists notes that don't have the tag yet
+	notes := []db.Note{
+		{ID: "n_1", Title: "Standup", Tags: []string{"standup"}},
+		{ID: "n_2", Title: "Standup notes", Tags: []string{}},
+		{ID: "n_3", Title: "Retro", Tags: []string{}},
+	}
+	results := tagRuleDryRun(rules[:1], notes)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, 2, results[0].Matches)
+	assert.Equal(t, 1, len(results[0].Notes))
+	assert.Equal(t, "n_2", results[0].Notes[0].ID)
+}
+
+func TestParseTagRule(t *testing.T) {
+	t.Parallel()
+
+	params, problems := parseTagRule(url.Values{"kind": {"url_domain"}, "value": {"https://Jira.Example.com/browse/X-1"}, "tag": {"#Jira"}})
+	assert.Equal(t, 0, len(problems))
+	assert.Equal(t, "jira.example.com", params.Value)
+	assert.Equal(t, "Jira", params.Tag)
+
+	params, problems = parseTagRule(url.Values{"kind": {"title_prefix"}, "value": {" Standup "}, "tag": {"standup"}})
+	assert.Equal(t, 0, len(problems))
+	assert.Equal(t, "Standup", params.Value)
+
+	_, problems = parseTagRule(url.Values{"kind": {"regex"}, "value": {""}, "tag": {"a b"}})
+	assert.Equal(t, 3, len(problems))
+}
+
+func TestSavedSearchQuery(t *testing.T) {
+	t.Parallel()
+
+	values, err := url.ParseQuery("q=&tag=recipe&favorites=on&notag=work&tag=baking&archived=")
+	assert.NoError(t, err)
+	assert.Equal(t, "favorites=on&notag=work&tag=recipe&tag=baking", savedSearchQuery(values))
+	assert.Equal(t, "", savedSearchQuery(url.Values{}))
+
+	// The parameters become search terms
+	params, err := searchParams(values)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(params.Query.Terms))
+	assert.Equal(t, 0, len(params.Query.Any))
+
+	values.Set("match", "any")
+	values.Set("q", `"unclosed`)
+	params, err = searchParams(values)
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 2, len(params.Query.Any))
+}
+
+func TestPageSize(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 50, pageSize("50"))
+	assert.Equal(t, defaultPageSize, pageSize(""))
+	assert.Equal(t, defaultPageSize, pageSize("7"))
+	assert.Equal(t, defaultPageSize, pageSize("ten"))
+}
+
+func TestMergeNoteText(t *testing.T) {
+	t.Parallel()
+
+	keep := db.Note{Title: "Bread", Note: "Sourdough starter\n"}
+	other := db.Note{Title: "Bread (imported)", Note: "\nRye flour"}
+
+	tests := []struct {
+		layout string
+		want   string
+	}{
+		{layout: "append", want: "Sourdough starter\n\n---\n\nRye flour"},
+		{layout: "prepend", want: "Rye flour\n\n---\n\nSourdough starter"},
+		{layout: "sections", want: "## Bread\n\nSourdough starter\n\n## Bread (imported)\n\nRye flour"},
+		{layout: "keep", want: "Sourdough starter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, true, isMergeLayout(tt.layout))
+			assert.Equal(t, tt.want, mergeNoteText(tt.layout, keep, other))
+		})
+	}
+
+	// Empty text is left out
+	assert.Equal(t, "Sourdough starter", mergeNoteText("append", keep, db.Note{Note: " \n"}))
+	assert.Equal(t, false, isMergeLayout("zip"))
+}
+
+func TestMergeNoteTags(t *testing.T) {
+	t.Parallel()
+
+	rules := []db.TagRule{{Kind: "note_contains", Value: "invoice", Tag: "finance"}}
+	keep := db.Note{Title: "Bread", Note: "#baking", ExplicitTags: []string{"Recipe"}, Tags: []string{"baking", "recipe"}}
+	other := db.Note{
+		Title:        "Bread",
+		Note:         "#rye invoice",
+		ExplicitTags: []string{"recipe", "shopping"},
+		Tags:         []string{"rye", "recipe", "shopping", "finance", "daily"},
+	}
+
+	// The other note's hashtags and rule tags become explicit tags when its text is left out
+	explicit, tags := mergeNoteTags(keep, other, mergeNoteText("keep", keep, other), rules)
+	assert.EqualSlices(t, []string{"Recipe", "shopping", "rye", "finance"}, explicit)
+	assert.EqualSlices(t, []string{"baking", "recipe", "shopping", "rye", "finance"}, tags)
+
+	// They come from the text when it is kept
+	explicit, tags = mergeNoteTags(keep, other, mergeNoteText("append", keep, other), rules)
+	assert.EqualSlices(t, []string{"Recipe", "shopping"}, explicit)
+	assert.EqualSlices(t, []string{"baking", "rye", "recipe", "shopping", "finance"}, tags)
+}
+
+func TestRetargetWikiLinks(t *testing.T) {
+	t.Parallel()
+
+	keep := db.Note{ID: "n_keep", Title: "Bread"}
+	other := db.Note{ID: "n_other", Title: "Rye Bread"}
+	text := "[[n_other]], [[n_other|rye]], [[rye bread]], [[Bread]] and `[[n_other]]`"
+
+	assert.Equal(t,
+		"[[n_keep]], [[n_keep|rye]], [[n_keep|rye bread]], [[Bread]] and `[[n_other]]`",
+		retargetWikiLinks(text, keep, other, true),
+	)
+
+	// Links by title are kept when the title links to another note
+	assert.Equal(t,
+		"[[n_keep]], [[n_keep|rye]], [[rye bread]], [[Bread]] and `[[n_other]]`",
+		retargetWikiLinks(text, keep, other, false),
+	)
+}
+
+func TestDuplicateThreshold(t *testing.T) {
+	t.Parallel()
+
+	threshold, err := duplicateThreshold("")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultDuplicateThreshold, threshold)
+
+	threshold, err = duplicateThreshold("0.8")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.8, threshold)
+
+	for _, value := range []string{"0", "1.5", "most"} {
+		_, err = duplicateThreshold(value)
+		assert.NotEqual(t, nil, err)
+	}
+}
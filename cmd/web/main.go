@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/gob"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 	"os/signal"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -25,6 +27,7 @@ import (
 	"github.com/alexedwards/scs/pgxstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/sglmr/go-notes/internal/email"
+	"github.com/sglmr/go-notes/internal/storage"
 )
 
 //=============================================================================
@@ -33,6 +36,13 @@ import (
 
 var timeLocation *time.Location
 
+// searchLanguage is the PostgreSQL text search configuration used for full text searches
+var searchLanguage = "english"
+
+// relatedThreshold is the lowest score, from the trigram similarity of the notes and their
+// shared tags, for a note to be listed in the related notes of another note
+var relatedThreshold = 0.3
+
 func init() {
 	gob.Register(FlashMessage{})
 	gob.Register([]FlashMessage{})
@@ -57,13 +67,15 @@ func newServer(
 	wg *sync.WaitGroup,
 	sessionManager *scs.SessionManager,
 	queries *db.Queries,
+	attachments storage.Store,
+	dailyNotes dailyNoteOptions,
 ) http.Handler {
 	// Create a serve mux
 	logger.Debug("creating server")
 	mux := http.NewServeMux()
 
 	// Add routes the ServeMux
-	addRoutes(mux, logger, devMode, authEmail, passwordHash, wg, sessionManager, queries)
+	addRoutes(mux, logger, devMode, authEmail, passwordHash, wg, sessionManager, queries, attachments, dailyNotes)
 
 	// Add middleare chain for all the routes
 	var handler http.Handler = mux
@@ -102,11 +114,18 @@ func runApp(
 	pgdsn := fs.String("db-dsn", getenv("NOTES_DB_DSN"), "PostgreSQL DSN")
 	migrate := fs.Bool("automigrate", true, "Automatically perform up migrations on startup")
 	location := fs.String("time-location", "America/Los_Angeles", "Time Location (default: America/Los_Angeles)")
-	_ = fs.String("smtp-host", "", "Email smtp host")
-	_ = fs.Int("smtp-port", 25, "Email smtp port")
-	_ = fs.String("smtp-username", "", "Email smtp username")
-	_ = fs.String("smtp-password", "", "Email smtp password")
-	_ = fs.String("smtp-from", "Eample Name <no-reply@example.com>", "Email smtp Sender")
+	language := fs.String("search-language", searchLanguage, "PostgreSQL text search configuration for full text search, like english, german or simple")
+	related := fs.Float64("related-threshold", relatedThreshold, "Lowest trigram similarity score, from 0 to 1, for related notes. Higher is stricter and faster")
+	attachmentDir := fs.String("attachment-dir", getenv("NOTES_ATTACHMENT_DIR"), "Directory for attachment files (default: store attachments in PostgreSQL)")
+	dailyTitleFormat := fs.String("daily-title-format", "Monday, January 2, 2006", "Go time layout for the titles of new daily notes")
+	dailyBody := fs.String("daily-body", "# {{title}}\n\n", "Starting body for new daily notes. Supports the {{date}}, {{time}}, {{weekday}} and {{title}} placeholders")
+	baseURL := fs.String("base-url", getenv("NOTES_BASE_URL"), "Public URL of the application for links in emails (default: http://localhost:<port>)")
+	trashRetention := fs.Duration("trash-retention", 30*24*time.Hour, "How long notes stay in the trash before they are deleted forever (0 keeps them)")
+	smtpHost := fs.String("smtp-host", "", "Email smtp host")
+	smtpPort := fs.Int("smtp-port", 25, "Email smtp port")
+	smtpUsername := fs.String("smtp-username", "", "Email smtp username")
+	smtpPassword := fs.String("smtp-password", "", "Email smtp password")
+	smtpFrom := fs.String("smtp-from", "Eample Name <no-reply@example.com>", "Email smtp Sender")
 
 	// Parse the flags
 	err := fs.Parse(args[1:])
@@ -120,6 +139,12 @@ func runApp(
 		return fmt.Errorf("load location error: %w", err)
 	}
 
+	// Check the related notes threshold
+	if *related < 0 || *related > 1 {
+		return fmt.Errorf("related threshold %v is not between 0 and 1", *related)
+	}
+	relatedThreshold = *related
+
 	// Connect to the PostgreSQL database
 	dbpool, err := pgxpool.New(ctx, *pgdsn)
 	if err != nil {
@@ -153,6 +178,9 @@ func runApp(
 	if *port == "" {
 		*port = "8000"
 	}
+	if *baseURL == "" {
+		*baseURL = "http://localhost:" + *port
+	}
 
 	// Create a new logger
 	logLevel := &slog.LevelVar{}
@@ -161,6 +189,39 @@ func runApp(
 		Level: logLevel,
 	}))
 
+	// Extract the tags of existing notes again after Unicode and mixed-case hashtags were
+	// supported. This also drops the hashtags in code spans and fenced code blocks.
+	if *migrate {
+		_, err := runDataMigration(ctx, queries, "unicode-tags", func(queries *db.Queries) error {
+			count, err := refreshAllNoteTags(ctx, logger, queries)
+			logger.Info("re-extracted note tags", "notes", count)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// Save the wiki links again without the links in code that the note_links migration added
+		_, err = runDataMigration(ctx, queries, "wiki-links-skip-code", func(queries *db.Queries) error {
+			count, err := refreshAllNoteLinks(ctx, queries)
+			logger.Info("saved note wiki links", "notes", count)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Index the notes for full text search in the search language
+	count, err := queries.SetSearchLanguage(ctx, *language)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		logger.Info("indexed notes for full text search", "notes", count, "language", *language)
+	}
+	searchLanguage = *language
+
 	// Create a mailer for sending emails
 	var mailer email.MailerInterface
 	switch {
@@ -170,15 +231,18 @@ func runApp(
 
 		// Configure email to send to log
 		mailer = email.NewLogMailer(logger)
+	case *smtpHost != "":
+		logLevel.Set(slog.LevelInfo)
+
+		// Configure a mailer to send real emails
+		mailer, err = email.NewMailer(*smtpHost, *smtpPort, *smtpUsername, *smtpPassword, *smtpFrom)
+		if err != nil {
+			logger.Error("smtp configuration error", "error", err)
+			return fmt.Errorf("smtp mailer setup failed: %w", err)
+		}
 	default:
 		logLevel.Set(slog.LevelInfo)
 		mailer = email.NewLogMailer(logger)
-		// TODO: Configure a mailer to send real emails
-		// mailer, err = email.NewMailer(*smtpHost, *smtpPort, *smtpUsername, *smtpPassword, *smtpFrom)
-		// if err != nil {
-		// logger.Error("smtp configuration error", "error", err)
-		// return fmt.Errorf("smtp mailer setup failed: %w", err)
-		// }
 	}
 
 	// Session manager configuration
@@ -189,8 +253,36 @@ func runApp(
 		sessionManager.Cookie.Secure = true
 	}
 
+	// Choose where to store attachment files
+	var attachments storage.Store
+	switch {
+	case *attachmentDir != "":
+		attachments, err = storage.NewDirStore(*attachmentDir)
+		if err != nil {
+			return fmt.Errorf("attachment storage setup failed: %w", err)
+		}
+	default:
+		attachments = storage.NewDBStore(queries)
+	}
+
+	// Daily note configuration
+	dailyNotes := dailyNoteOptions{
+		TitleFormat: *dailyTitleFormat,
+		Body:        strings.ReplaceAll(*dailyBody, `\n`, "\n"),
+	}
+
+	// Permanently delete notes that have been in the trash for longer than the retention period
+	if *trashRetention > 0 {
+		backgroundTask(&wg, logger, purgeTrashTask(ctx, logger, queries, attachments, *trashRetention))
+	}
+
+	// Email note reminders when they are due
+	if *authEmail != "" {
+		backgroundTask(&wg, logger, reminderTask(ctx, logger, queries, mailer, *authEmail, strings.TrimSuffix(*baseURL, "/")))
+	}
+
 	// Set up router
-	srv := newServer(logger, *devMode, mailer, *authEmail, *authPasswordHash, &wg, sessionManager, queries)
+	srv := newServer(logger, *devMode, mailer, *authEmail, *authPasswordHash, &wg, sessionManager, queries, attachments, dailyNotes)
 
 	// Configure an http server
 	httpServer := &http.Server{
@@ -272,3 +364,122 @@ func backgroundTask(wg *sync.WaitGroup, logger *slog.Logger, fn func() error) {
 		}
 	}()
 }
+
+// purgeTrashTask returns a task that deletes notes from the trash once they are older than
+// the retention period. The task checks the trash every hour until ctx is cancelled.
+func purgeTrashTask(ctx context.Context, logger *slog.Logger, queries *db.Queries, attachments storage.Store, retention time.Duration) func() error {
+	return func() error {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			count, err := purgeTrash(ctx, logger, queries, attachments, time.Now().Add(-retention))
+			switch {
+			case errors.Is(err, context.Canceled):
+				return nil
+			case err != nil:
+				logger.Error("purge trash error", "error", err)
+			case count > 0:
+				logger.Info("purged notes from the trash", "count", count)
+			}
+
+			// Wait for the next check or for the application to shut down
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// purgeTrash permanently deletes the notes trashed before a time along with their attachment files
+func purgeTrash(ctx context.Context, logger *slog.Logger, queries *db.Queries, attachments storage.Store, before time.Time) (int64, error) {
+	// Find the attachments that will be deleted with the notes
+	attachmentIDs, err := queries.ListTrashedAttachments(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("list trashed attachments: %w", err)
+	}
+
+	count, err := queries.PurgeTrashedNotes(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("purge trashed notes: %w", err)
+	}
+
+	deleteAttachmentFiles(ctx, logger, attachments, attachmentIDs)
+	return count, nil
+}
+
+// reminderTask returns a task that emails note reminders to the recipient once they are due.
+// The task checks for due reminders every minute until ctx is cancelled.
+func reminderTask(ctx context.Context, logger *slog.Logger, queries *db.Queries, mailer email.MailerInterface, recipient, baseURL string) func() error {
+	return func() error {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			count, err := sendReminders(ctx, logger, queries, mailer, recipient, baseURL, time.Now())
+			switch {
+			case errors.Is(err, context.Canceled):
+				return nil
+			case err != nil:
+				logger.Error("send reminders error", "error", err)
+			case count > 0:
+				logger.Info("sent note reminders", "count", count)
+			}
+
+			// Wait for the next check or for the application to shut down
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// maxReminderAttempts is how many times a reminder email is tried before it is given up on
+const maxReminderAttempts = 8
+
+// reminderRetryDelay returns how long to wait before trying a reminder email again after
+// its attempts have failed. The wait doubles after each failure, starting at a minute.
+func reminderRetryDelay(attempts int32) time.Duration {
+	return time.Minute << (attempts - 1)
+}
+
+// sendReminders emails the reminders that are due at a time. Reminders are marked as sent before
+// the emails go out so that a reminder is never sent twice, and released again if the email fails.
+// A reminder that fails maxReminderAttempts times stays marked as sent.
+func sendReminders(ctx context.Context, logger *slog.Logger, queries *db.Queries, mailer email.MailerInterface, recipient, baseURL string, now time.Time) (int, error) {
+	notes, err := queries.ClaimDueReminders(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("claim due reminders: %w", err)
+	}
+
+	count := 0
+	for _, note := range notes {
+		data := map[string]any{
+			"Note":     note,
+			"RemindAt": note.RemindAt.In(timeLocation),
+			"URL":      fmt.Sprintf("%s/note/%s/", baseURL, note.ID),
+		}
+		if err := mailer.Send(recipient, recipient, data, "reminder.tmpl"); err != nil {
+			logger.Error("send reminder email", "note_id", note.ID, "error", err)
+
+			// Try the reminder again later, unless it has failed too many times
+			params := db.FailReminderParams{ID: note.ID}
+			if attempts := note.ReminderAttempts + 1; attempts < maxReminderAttempts {
+				retryAt := now.Add(reminderRetryDelay(attempts))
+				params.RetryAt = &retryAt
+			} else {
+				logger.Error("giving up on reminder", "note_id", note.ID, "attempts", attempts)
+			}
+			if err := queries.FailReminder(ctx, params); err != nil {
+				logger.Error("fail reminder", "note_id", note.ID, "error", err)
+			}
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/justinas/nosurf"
+	"github.com/sglmr/go-notes/db"
 	"github.com/sglmr/go-notes/internal/argon2id"
 )
 
@@ -212,3 +213,27 @@ func authenticateMW(sessionManager *scs.SessionManager) func(http.Handler) http.
 		})
 	}
 }
+
+// navMW adds a loader for the pinned tags and searches in the nav to the request context.
+// They are only queried when a page with the nav is rendered.
+func navMW(logger *slog.Logger, queries *db.Queries) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nav := &navData{ctx: r.Context(), logger: logger, queries: queries}
+			ctx := context.WithValue(r.Context(), navContextKey, nav)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// wikiLinksMW adds a resolver for the [[wiki links]] in the notes a page renders to the
+// request context. It looks up the links with the request context.
+func wikiLinksMW(logger *slog.Logger, queries *db.Queries) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resolver := wikiLinkResolver(r.Context(), logger, queries)
+			ctx := context.WithValue(r.Context(), wikiLinksContextKey, resolver)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -6,7 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,7 +21,11 @@ import (
 	"github.com/sglmr/go-notes/assets"
 	"github.com/sglmr/go-notes/db"
 	"github.com/sglmr/go-notes/internal/argon2id"
+	"github.com/sglmr/go-notes/internal/diff"
 	"github.com/sglmr/go-notes/internal/render"
+	"github.com/sglmr/go-notes/internal/search"
+	"github.com/sglmr/go-notes/internal/storage"
+	"github.com/sglmr/go-notes/internal/tasklist"
 	"github.com/sglmr/go-notes/internal/validator"
 	"github.com/sglmr/go-notes/internal/vcs"
 )
@@ -31,6 +39,8 @@ func addRoutes(
 	wg *sync.WaitGroup,
 	sessionManager *scs.SessionManager,
 	queries *db.Queries,
+	attachments storage.Store,
+	dailyNotes dailyNoteOptions,
 ) {
 	// Set up file server for embedded static files
 	fileServer := http.FileServer(http.FS(staticFileSystem{assets.EmbeddedFiles}))
@@ -46,20 +56,57 @@ func addRoutes(
 
 	// These routes are protected
 	protected := func(next http.Handler) http.Handler {
-		return requireLoginMW()(dynamic(next))
+		return requireLoginMW()(dynamic(navMW(logger, queries)(wikiLinksMW(logger, queries)(next))))
 	}
 	mux.Handle("GET /", protected(home(logger, devMode, sessionManager, queries)))
 	mux.Handle("GET /notes/list/", protected(listNotes(logger, devMode, sessionManager, queries)))
 	mux.Handle("GET /notes/search/", protected(listNotes(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /notes/bulk/", protected(bulkNotes(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /tags/{$}", protected(listTags(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /tag-rename/", protected(renameTagPreview(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /tag-rename/", protected(renameTag(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /tag-rules/{$}", protected(tagRules(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /tag-rules/{$}", protected(createTagRule(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /tag-rules/dry-run/", protected(tagRulesDryRun(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /tag-rules/{id}/delete/", protected(deleteTagRule(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /searches/{$}", protected(savedSearches(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /searches/{$}", protected(createSavedSearch(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /searches/{id}/", protected(updateSavedSearch(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /searches/{id}/delete/", protected(deleteSavedSearch(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /tags/{name...}", protected(viewTag(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /tags/{name...}", protected(editTag(logger, devMode, sessionManager, queries)))
 	mux.Handle("GET /notes/refresh-tags/", protected(refreshNoteTags(logger, wg, devMode, sessionManager, queries)))
+	mux.Handle("GET /notes/trash/", protected(listTrash(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /notes/trash/{id}/restore/", protected(restoreTrashedNote(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /notes/trash/{id}/delete/", protected(deleteTrashedNote(logger, devMode, sessionManager, queries, attachments)))
+	mux.Handle("GET /notes/duplicates/{$}", protected(duplicateNotes(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /notes/duplicates/merge/", protected(mergeNotesPreview(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /notes/duplicates/merge/", protected(mergeNotes(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /notebooks/", protected(listNotebooks(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /notebooks/new/", protected(createNotebook(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /notebook/{id}/edit/", protected(updateNotebook(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /notebook/{id}/delete/", protected(deleteNotebook(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /tasks/", protected(listTasks(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /notes/daily/", protected(dailyNote(logger, devMode, queries, dailyNotes)))
+	mux.Handle("GET /notes/daily/{date}/", protected(dailyNote(logger, devMode, queries, dailyNotes)))
 	mux.Handle("GET /note/{id}/", protected(viewNote(logger, devMode, sessionManager, queries)))
 	mux.Handle("GET /note/{id}/print/", protected(viewNote(logger, devMode, sessionManager, queries)))
 	mux.Handle("GET /notes/new/", protected(noteFormGet(logger, devMode, sessionManager, queries)))
-	mux.Handle("POST /notes/new/", protected(noteFormPOST(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /notes/new/", protected(noteFormPOST(logger, devMode, sessionManager, queries, attachments)))
+	mux.Handle("POST /notes/new/draft/", protected(saveNoteDraft(logger, devMode, queries)))
+	mux.Handle("POST /notes/new/draft/delete/", protected(deleteNoteDraft(logger, devMode, sessionManager, queries)))
 	mux.Handle("GET /note/{id}/delete/", protected(deleteNote(logger, devMode, sessionManager, queries)))
 	mux.Handle("POST /note/{id}/delete/", protected(deleteNote(logger, devMode, sessionManager, queries)))
 	mux.Handle("GET /note/{id}/edit/", protected(noteFormGet(logger, devMode, sessionManager, queries)))
-	mux.Handle("POST /note/{id}/edit/", protected(noteFormPOST(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /note/{id}/edit/", protected(noteFormPOST(logger, devMode, sessionManager, queries, attachments)))
+	mux.Handle("POST /note/{id}/draft/", protected(saveNoteDraft(logger, devMode, queries)))
+	mux.Handle("POST /note/{id}/draft/delete/", protected(deleteNoteDraft(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /note/{id}/history/", protected(noteHistory(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /note/{id}/history/{revisionID}/", protected(viewNoteRevision(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /note/{id}/history/{revisionID}/restore/", protected(restoreNoteRevision(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /note/{id}/task/", protected(toggleTask(logger, devMode, sessionManager, queries)))
+	mux.Handle("POST /note/{id}/snooze/", protected(snoozeReminder(logger, devMode, sessionManager, queries)))
+	mux.Handle("GET /attachment/{id}/", protected(viewAttachment(logger, devMode, queries, attachments)))
 	mux.Handle("GET /time/", protected(timeZone(logger, devMode, sessionManager)))
 	mux.Handle("POST /time/", protected(timeZone(logger, devMode, sessionManager)))
 	mux.Handle("GET /import/", protected(importNote(queries)))
@@ -285,39 +332,140 @@ func listNotes(
 	queries *db.Queries,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := db.SearchNotesParams{
-			Query:     r.URL.Query().Get("q"),
-			Tags:      []string{r.URL.Query().Get("tag")},
-			Archived:  len(r.URL.Query().Get("archived")) > 0,
-			Favorites: len(r.URL.Query().Get("favorites")) > 0,
+		selectedTags := filterTags(r.URL.Query()["tag"])
+		excludedTags := filterTags(r.URL.Query()["notag"])
+		matchAny := r.URL.Query().Get("match") == "any"
+		favorites := len(r.URL.Query().Get("favorites")) > 0
+		archived := len(r.URL.Query().Get("archived")) > 0
+
+		compact := len(r.URL.Query().Get("compact")) > 0
+		size := pageSize(r.URL.Query().Get("size"))
+
+		// Parse the search. A query that doesn't parse shows the problem instead of notes.
+		params, searchErr := searchParams(r.URL.Query())
+
+		// Start the page after the last note of the previous page
+		if after := r.URL.Query().Get("after"); after != "" {
+			cursor, err := db.ParseSearchCursor(after)
+			if err != nil {
+				clientError(w, http.StatusBadRequest)
+				return
+			}
+			params.After = &cursor
 		}
 
+		// Ask for one more note than fits on the page to know if there is a next page
+		params.Limit = size + 1
+
 		logger.Debug("notes params", "urlPath", r.URL.Path, "params", params)
 
-		// Query the database for the notes
-		notes, err := queries.SearchNotes(r.Context(), params)
+		// Query the database for a page of notes, the number of notes and the counts of their tags
+		notes := []db.SearchNotesRow{}
+		total := int64(0)
+		tagCounts := []db.TagCount{}
+		if searchErr == nil {
+			var err error
+			notes, err = queries.SearchNotes(r.Context(), params)
+			if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+			total, err = queries.CountSearchNotes(r.Context(), params)
+			if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+			tagCounts, err = queries.SearchTagCounts(r.Context(), params)
+			if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+		}
+
+		nextCursor := ""
+		if len(notes) > size {
+			notes = notes[:size]
+			nextCursor = notes[size-1].Cursor(params).String()
+		}
+
+		// Query for a list of tags
+		tagList, err := queries.GetTagsWithCounts(r.Context())
 		if err != nil {
 			serverError(w, r, err, logger, showTrace)
 			return
 		}
 
-		// Query for a list of tags
-		tagList, err := queries.GetTagsWithCounts(r.Context())
+		// Query for the notebooks
+		notebooks, err := queries.ListNotebooks(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Query for the tag colors
+		tags, err := queries.ListTags(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Look for a saved search with the same query
+		savedSearches, err := queries.ListSavedSearches(r.Context())
 		if err != nil {
 			serverError(w, r, err, logger, showTrace)
 			return
 		}
+		searchQuery := savedSearchQuery(r.URL.Query())
+		var savedSearch *db.SavedSearch
+		for _, saved := range savedSearches {
+			if saved.Query == searchQuery {
+				savedSearch = &saved
+				break
+			}
+		}
 
-		logger.Debug("query counts", "notes", len(notes), "tags", len(tagList))
+		logger.Debug("query counts", "notes", len(notes), "tags", len(tagList), "notebooks", len(notebooks))
+
+		// Show the tags of the notes as they are written
+		labels := map[string]string{}
+		for _, note := range notes {
+			noteTagLabels(note.Note, labels)
+		}
+
+		// Arrange the tags into a tree with the counts for the search and the filtered tags open
+		tree := tagTree(facetTags(tagList, tagCounts))
+		selectTags(tree, selectedTags, excludedTags)
 
 		// Prepare template data
 		data := newTemplateData(r, sessionManager)
 		data["Q"] = r.URL.Query().Get("q")
-		data["Tag"] = r.URL.Query().Get("tag")
-		data["Favorites"] = params.Favorites
-		data["Archived"] = params.Archived
+		data["SearchError"] = searchErr
+		data["Tags"] = selectedTags
+		data["ExcludedTags"] = excludedTags
+		data["TagChips"] = tagChips(r.URL, selectedTags, excludedTags)
+		data["MatchAny"] = matchAny
+		data["Favorites"] = favorites
+		data["Archived"] = archived
 		data["Notes"] = notes
 		data["TagList"] = tagList
+		data["TagTree"] = tree
+		data["TagLabels"] = labels
+		data["TagColors"] = tagColors(tags)
+		data["Notebook"] = params.NotebookID
+		data["NotebookPath"] = notebookPath(notebooks, params.NotebookID)
+		data["NotebookTree"] = notebookTree(notebooks)
+		data["CurrentURL"] = r.URL.RequestURI()
+		data["SearchQuery"] = searchQuery
+		data["SavedSearch"] = savedSearch
+		data["URL"] = r.URL
+		data["Total"] = total
+		data["NextCursor"] = nextCursor
+		data["Paged"] = params.After != nil
+		data["Sort"] = r.URL.Query().Get("sort")
+		data["Sorts"] = noteSorts
+		data["PageSize"] = size
+		data["PageSizes"] = pageSizes
+		data["Compact"] = compact
 
 		// Render the page
 		if err := render.Page(w, http.StatusOK, data, "listNotes.tmpl"); err != nil {
@@ -327,6 +475,115 @@ func listNotes(
 	}
 }
 
+// bulkNotes applies an action to the notes selected on the notes list in one transaction
+func bulkNotes(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		next := localRedirectURL(r.PostForm.Get("next"), "/notes/list/")
+		action := r.PostForm.Get("action")
+		tag := parseTag(r.PostForm.Get("tag"))
+
+		// Skip repeated note IDs
+		ids := slices.Clone(r.PostForm["id"])
+		slices.Sort(ids)
+		ids = slices.Compact(ids)
+
+		switch action {
+		case "archive", "unarchive", "favorite", "unfavorite", "trash":
+		case "add-tag", "remove-tag":
+			if !validTag(tag) {
+				putFlashMessage(r, flashError, "Enter a tag with letters, numbers, dashes and / for nested tags.", sessionManager)
+				http.Redirect(w, r, next, http.StatusSeeOther)
+				return
+			}
+		default:
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		if len(ids) == 0 {
+			putFlashMessage(r, flashError, "Select one or more notes first.", sessionManager)
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
+		}
+
+		// Apply the action to every note, or none of them if there is an error
+		count := 0
+		err := queries.InTx(r.Context(), func(queries *db.Queries) error {
+			for _, id := range ids {
+				note, err := queries.GetNote(r.Context(), id)
+				if errors.Is(err, pgx.ErrNoRows) {
+					continue
+				} else if err != nil {
+					return err
+				}
+
+				switch action {
+				case "archive":
+					err = queries.ArchiveNote(r.Context(), id)
+				case "unarchive":
+					err = queries.UnarchiveNote(r.Context(), id)
+				case "favorite":
+					err = queries.FavoriteNote(r.Context(), id)
+				case "unfavorite":
+					err = queries.UnfavoriteNote(r.Context(), id)
+				case "trash":
+					_, err = queries.TrashNote(r.Context(), id)
+				case "add-tag", "remove-tag":
+					text, explicit := note.Note, note.ExplicitTags
+					switch {
+					case action == "remove-tag":
+						text = removeTagFromText(note.Note, tag)
+						explicit = removeTag(note.ExplicitTags, tag)
+					case !slices.Contains(noteTags(note.Note, note.ExplicitTags, false), normalizeTag(tag)):
+						text = addTagToText(note.Note, tag)
+					}
+
+					// Only count the notes that changed
+					if text == note.Note && slices.Equal(explicit, note.ExplicitTags) {
+						continue
+					}
+					err = updateNoteText(r.Context(), queries, note, text, explicit)
+				}
+				if err != nil {
+					return fmt.Errorf("%s note %s: %w", action, id, err)
+				}
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Summarize the changes
+		notes := fmt.Sprintf("%d notes", count)
+		if count == 1 {
+			notes = "1 note"
+		}
+		summary := map[string]string{
+			"archive":    "Archived " + notes + ".",
+			"unarchive":  "Unarchived " + notes + ".",
+			"favorite":   "Added " + notes + " to favorites.",
+			"unfavorite": "Removed " + notes + " from favorites.",
+			"trash":      "Moved " + notes + " to the trash.",
+			"add-tag":    fmt.Sprintf("Added #%s to %s.", tag, notes),
+			"remove-tag": fmt.Sprintf("Removed #%s from %s.", tag, notes),
+		}
+		putFlashMessage(r, flashSuccess, summary[action], sessionManager)
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
+
 // refreshNoteTags refreshes all the note tags on a get request
 func refreshNoteTags(
 	logger *slog.Logger,
@@ -343,26 +600,9 @@ func refreshNoteTags(
 				ctx, ctxCancel := context.WithTimeout(context.Background(), time.Minute*2)
 				defer ctxCancel()
 
-				// Get a list of all the notes
-				notes, err := queries.ListAllNotes(ctx)
-				if err != nil {
-					return err
-				}
-
-				// update the tag for each note
-				for _, note := range notes {
-					params := db.UpdateNoteTagsParams{
-						ID:   note.ID,
-						Tags: extractTags(note.Note),
-					}
-					_, err := queries.UpdateNoteTags(ctx, params)
-					if err != nil {
-						logger.Error("update note tags error", "note", note.Title, "note_id", note.ID, "error", err)
-					} else {
-						logger.Debug("updated note tags", "note", note.Title, "note_id", note.ID)
-					}
-				}
-				return nil
+				count, err := refreshAllNoteTags(ctx, logger, queries)
+				logger.Info("refreshed note tags", "notes", count)
+				return err
 			})
 
 		putFlashMessage(r, flashSuccess, "Queued background task to update notes.", sessionManager)
@@ -371,372 +611,2264 @@ func refreshNoteTags(
 	}
 }
 
-// viewNote displays a single note
-func viewNote(
+// listTags displays the tags with their note counts and a form to rename each one
+func listTags(
 	logger *slog.Logger,
 	showTrace bool,
 	sessionManager *scs.SessionManager,
 	queries *db.Queries,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Create a new template data file
-		data := newTemplateData(r, sessionManager)
-
-		// Check if there is an id value for the note
-		id := r.PathValue("id")
-
-		// Query for a single note
-		note, err := queries.GetNote(r.Context(), id)
-		if errors.Is(err, pgx.ErrNoRows) {
-			clientError(w, http.StatusNotFound)
-			return
-		} else if err != nil {
+		tags, err := queries.GetTagsWithCounts(r.Context())
+		if err != nil {
 			serverError(w, r, err, logger, showTrace)
 			return
 		}
 
-		// Add the note data to the template data map
-		data["Note"] = note
-
-		// Choose print vs regular view
-		switch {
-		// Render the page without the base template
-		case strings.HasSuffix(r.URL.Path, "/print/"):
-			if err := render.NamedTemplate(w, http.StatusOK, data, "base", "printBase.tmpl", "pages/viewNote.tmpl"); err != nil {
-				serverError(w, r, err, logger, showTrace)
-				return
-			}
-		default:
-			// Render the page
-			if err := render.Page(w, http.StatusOK, data, "viewNote.tmpl"); err != nil {
-				serverError(w, r, err, logger, showTrace)
-				return
-			}
+		data := newTemplateData(r, sessionManager)
+		data["Tags"] = tags
 
+		if err := render.Page(w, http.StatusOK, data, "tags.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
 		}
 	}
 }
 
-// deleteNote deletes a note
-func deleteNote(
+// renameTagPreview displays the notes that change when a tag is renamed or merged into another tag
+func renameTagPreview(
 	logger *slog.Logger,
 	showTrace bool,
 	sessionManager *scs.SessionManager,
 	queries *db.Queries,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check if there is an id value for the note
-		id := r.PathValue("id")
+		from := normalizeTag(parseTag(r.URL.Query().Get("from")))
+		to := parseTag(r.URL.Query().Get("to"))
+		if msg := checkTagRename(from, to); msg != "" {
+			putFlashMessage(r, flashError, msg, sessionManager)
+			http.Redirect(w, r, "/tags/", http.StatusSeeOther)
+			return
+		}
 
-		// Query for a single note
-		note, err := queries.GetNote(r.Context(), id)
-		if errors.Is(err, pgx.ErrNoRows) {
-			clientError(w, http.StatusNotFound)
+		notes, err := queries.ListActiveNotes(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
 			return
-		} else if err != nil {
+		}
+		tags, err := queries.GetTagsWithCounts(r.Context())
+		if err != nil {
 			serverError(w, r, err, logger, showTrace)
 			return
 		}
 
-		switch r.Method {
-		case http.MethodGet:
-			// Create a new template data file
-			data := newTemplateData(r, sessionManager)
-			data["Note"] = note
+		data := newTemplateData(r, sessionManager)
+		data["From"] = from
+		data["To"] = to
+		data["Merge"] = normalizeTag(to) != from &&
+			slices.ContainsFunc(tags, func(tag db.TagSummary) bool { return tag.TagName == normalizeTag(to) })
+		data["Renames"] = tagRenames(notes, from, to)
 
-			// Render the page
-			if err := render.Page(w, http.StatusOK, data, "deleteNote.tmpl"); err != nil {
-				serverError(w, r, err, logger, showTrace)
-				return
-			}
+		if err := render.Page(w, http.StatusOK, data, "renameTag.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
 			return
-		case http.MethodPost:
-			err := queries.DeleteNote(r.Context(), id)
-			if err != nil {
-				serverError(w, r, err, logger, showTrace)
-			}
+		}
+	}
+}
 
-			http.Redirect(w, r, "/notes/list/", http.StatusSeeOther)
+// renameTag rewrites the hashtags for a tag in every note in one transaction
+func renameTag(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		from := normalizeTag(parseTag(r.PostForm.Get("from")))
+		to := parseTag(r.PostForm.Get("to"))
+		if msg := checkTagRename(from, to); msg != "" {
+			putFlashMessage(r, flashError, msg, sessionManager)
+			http.Redirect(w, r, "/tags/", http.StatusSeeOther)
 			return
+		}
 
-		default:
-			clientError(w, http.StatusNotFound)
+		// Rewrite every note with the tag, or none of them if there is an error. Notes in
+		// the trash can't be edited, so they keep the old tag.
+		count := 0
+		err := queries.InTx(r.Context(), func(queries *db.Queries) error {
+			notes, err := queries.ListActiveNotes(r.Context())
+			if err != nil {
+				return err
+			}
+			for _, rename := range tagRenames(notes, from, to) {
+				if err := updateNoteText(r.Context(), queries, rename.Note, rename.Text, rename.ExplicitTags); err != nil {
+					return fmt.Errorf("rename tag in note %s: %w", rename.Note.ID, err)
+				}
+				count++
+			}
+
+			// Move the tag description and color to the new name, unless the
+			// tag it is merged into has its own
+			if normalizeTag(to) == from {
+				return nil
+			}
+			if err := queries.RenameTag(r.Context(), db.RenameTagParams{ToName: normalizeTag(to), FromName: from}); err != nil {
+				return fmt.Errorf("rename tag metadata: %w", err)
+			}
+			return queries.DeleteTag(r.Context(), from)
+		})
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
 			return
 		}
+
+		logger.Info("renamed tag", "from", from, "to", to, "notes", count)
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Renamed #%s to #%s in %d note(s).", from, to, count), sessionManager)
+		http.Redirect(w, r, "/tags/", http.StatusSeeOther)
 	}
 }
 
-// noteFormGet displays an editor for creating or updating notes
-func noteFormGet(
+// viewTag displays a tag with its description and notes, and a form to edit the tag
+func viewTag(
 	logger *slog.Logger,
 	showTrace bool,
 	sessionManager *scs.SessionManager,
 	queries *db.Queries,
 ) http.HandlerFunc {
-	type noteForm struct {
-		Title     string
-		Note      string
-		Favorite  bool
-		Archive   bool
-		CreatedAt time.Time
-		validator.Validator
-	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		data := newTemplateData(r, sessionManager)
-		form := noteForm{
-			Title:     "",
-			Note:      "",
-			Favorite:  false,
-			Archive:   false,
-			CreatedAt: time.Now().In(timeLocation),
+		name := normalizeTag(strings.TrimSuffix(r.PathValue("name"), "/"))
+		if !validTag(name) {
+			clientError(w, http.StatusNotFound)
+			return
 		}
 
-		// Check if there is an id value in the url path
-		id := r.PathValue("id")
+		// A tag without a description or color yet has no metadata row
+		tag, err := queries.GetTag(r.Context(), name)
+		if errors.Is(err, pgx.ErrNoRows) {
+			tag = db.Tag{Name: name}
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
 
-		// Query for a single note if there is an id
-		if id != "" {
-			note, err := queries.GetNote(r.Context(), id)
-			if errors.Is(err, pgx.ErrNoRows) {
-				clientError(w, http.StatusNotFound)
-				return
-			} else if err != nil {
-				serverError(w, r, err, logger, showTrace)
-				return
-			}
+		// Query for the notes with the tag or a tag nested inside it
+		notes, err := queries.SearchNotes(r.Context(), db.SearchNotesParams{
+			Query:    search.Query{Terms: []search.Term{{Field: search.FieldTag, Value: name}}},
+			Language: searchLanguage,
+		})
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
 
-			data["Note"] = note
+		labels := map[string]string{}
+		for _, note := range notes {
+			noteTagLabels(note.Note, labels)
+		}
 
-			// Fill in the form with the Note data
-			form = noteForm{
-				Title:     note.Title,
-				Note:      note.Note,
-				Favorite:  note.Favorite,
-				Archive:   note.Archive,
-				CreatedAt: note.CreatedAt,
-			}
+		// Query for the tag colors
+		tags, err := queries.ListTags(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
 		}
 
-		// Populate the Form Data
-		data["Form"] = form
+		data := newTemplateData(r, sessionManager)
+		data["Tag"] = tag
+		data["Notes"] = notes
+		data["TagLabels"] = labels
+		data["TagColors"] = tagColors(tags)
 
-		// Render the page
-		if err := render.Page(w, http.StatusOK, data, "noteForm.tmpl"); err != nil {
+		if err := render.Page(w, http.StatusOK, data, "viewTag.tmpl"); err != nil {
 			serverError(w, r, err, logger, showTrace)
 			return
 		}
 	}
 }
 
-// importNote handles POST requests to insert a note
-func importNote(
+// editTag saves the description, color and pinned flag of a tag
+func editTag(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
 	queries *db.Queries,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
-			// Set content type to plain text
-			w.Header().Set("Content-Type", "text/plain")
-
-			// Write a text response
-			fmt.Fprint(w, nosurf.Token(r))
+		name := normalizeTag(strings.TrimSuffix(r.PathValue("name"), "/"))
+		if !validTag(name) {
+			clientError(w, http.StatusNotFound)
 			return
 		}
-
-		// Return Bad Request if the form data is not parseable
-		err := r.ParseForm()
-		if err != nil {
+		if err := r.ParseForm(); err != nil {
 			clientError(w, http.StatusBadRequest)
 			return
 		}
 
-		noteID := r.FormValue("note_id")
-		if noteID == "" {
-			fmt.Fprintln(w, "missing note_id")
-			clientError(w, http.StatusUnprocessableEntity)
+		params := db.SaveTagParams{
+			Name:        name,
+			Description: strings.TrimSpace(r.PostForm.Get("description")),
+			Color:       strings.ToLower(r.PostForm.Get("color")),
+			Pinned:      r.PostForm.Get("pinned") != "",
+		}
+		if r.PostForm.Get("no_color") != "" {
+			params.Color = ""
+		}
+
+		url := fmt.Sprintf("/tags/%s/", name)
+		if params.Color != "" && !colorRX.MatchString(params.Color) {
+			putFlashMessage(r, flashError, "Choose a color for the tag.", sessionManager)
+			http.Redirect(w, r, url, http.StatusSeeOther)
 			return
 		}
-		title := r.FormValue("title")
-		if title == "" {
-			fmt.Fprintln(w, "missing title")
-			clientError(w, http.StatusUnprocessableEntity)
+
+		if _, err := queries.SaveTag(r.Context(), params); err != nil {
+			serverError(w, r, err, logger, showTrace)
 			return
 		}
-		note := r.FormValue("note")
-		if note == "" {
-			fmt.Fprintln(w, "missing note")
-			clientError(w, http.StatusUnprocessableEntity)
+
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Saved #%s.", name), sessionManager)
+		http.Redirect(w, r, url, http.StatusSeeOther)
+	}
+}
+
+// tagRules displays the automatic tagging rules with a form to add a rule
+func tagRules(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := queries.ListTagRules(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
 			return
 		}
-		archive := len(r.FormValue("archive")) > 0
-		favorite := len(r.FormValue("favorite")) > 0
-
-		// Convert the value to time.Time
-		createdAt, err := time.ParseInLocation(time.RFC3339, r.FormValue("created_at"), timeLocation)
-		if err != nil || createdAt.IsZero() {
-			fmt.Fprintln(w, "missing or invalid created_at")
-			clientError(w, http.StatusUnprocessableEntity)
+		tags, err := queries.GetTagsWithCounts(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
 			return
 		}
-		// Convert the value to time.Time
-		modifiedAt, err := time.ParseInLocation(time.RFC3339, r.FormValue("modified_at"), timeLocation)
-		if err != nil || modifiedAt.IsZero() {
-			fmt.Fprintln(w, "missing or invalid modified_at")
-			clientError(w, http.StatusUnprocessableEntity)
+
+		data := newTemplateData(r, sessionManager)
+		data["Rules"] = rules
+		data["RuleKinds"] = tagRuleKinds
+		data["TagList"] = tags
+
+		if err := render.Page(w, http.StatusOK, data, "tagRules.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
 			return
 		}
+	}
+}
 
-		params := db.ImportNoteParams{
-			ID:         noteID,
-			Title:      title,
-			Note:       note,
-			Archive:    archive,
-			Favorite:   favorite,
-			CreatedAt:  createdAt,
-			ModifiedAt: modifiedAt,
-			Tags:       extractTags(note),
+// tagRulesDryRun shows the notes that each tagging rule would add its tag to. With a kind,
+// value and tag in the query it previews a new rule before it is created.
+func tagRulesDryRun(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := newTemplateData(r, sessionManager)
+
+		var rules []db.TagRule
+		if r.URL.Query().Has("kind") {
+			params, problems := parseTagRule(r.URL.Query())
+			for _, message := range problems {
+				putFlashMessage(r, flashError, message, sessionManager)
+			}
+			if len(problems) > 0 {
+				http.Redirect(w, r, "/tag-rules/", http.StatusSeeOther)
+				return
+			}
+			rules = []db.TagRule{{Kind: params.Kind, Value: params.Value, Tag: params.Tag}}
+			data["NewRule"] = params
+		} else {
+			var err error
+			rules, err = queries.ListTagRules(r.Context())
+			if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
 		}
 
-		n, err := queries.ImportNote(r.Context(), params)
+		// Notes in the trash aren't listed anywhere else, so leave them out of the preview
+		notes, err := queries.ListActiveNotes(r.Context())
 		if err != nil {
-			w.Header().Set("Content-Type", "text/plain")
-			fmt.Fprintf(w, "error importing %s: %s", noteID, err.Error())
+			serverError(w, r, err, logger, showTrace)
 			return
 		}
 
-		w.Header().Set("Content-Type", "text/plain")
-		fmt.Fprintf(w, "created note: %v", n.ID)
+		data["Results"] = tagRuleDryRun(rules, notes)
+		data["RuleKinds"] = tagRuleKinds
+
+		if err := render.Page(w, http.StatusOK, data, "tagRulesDryRun.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
 	}
 }
 
-// noteFormPost handles POST requests to update or create notes
-func noteFormPOST(
+// createTagRule saves a new tagging rule and adds its tag to the notes that match
+func createTagRule(
 	logger *slog.Logger,
 	showTrace bool,
 	sessionManager *scs.SessionManager,
 	queries *db.Queries,
 ) http.HandlerFunc {
-	type noteForm struct {
-		Title     string
-		Note      string
-		Favorite  bool
-		Archive   bool
-		CreatedAt time.Time
-		validator.Validator
-	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		var err error
-		var note db.Note
-
-		// Return Bad Request if the form data is not parseable
-		if err = r.ParseForm(); err != nil {
+		if err := r.ParseForm(); err != nil {
 			clientError(w, http.StatusBadRequest)
 			return
 		}
 
-		// Check if there is an id value in the url path
+		params, problems := parseTagRule(r.PostForm)
+		for _, message := range problems {
+			putFlashMessage(r, flashError, message, sessionManager)
+		}
+		if len(problems) > 0 {
+			http.Redirect(w, r, "/tag-rules/", http.StatusSeeOther)
+			return
+		}
+
+		// Create an ID for the rule
+		id, err := db.GenerateID("tr")
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+		params.ID = id
+
+		// Save the rule and update the tags of the notes together
+		count := 0
+		err = queries.InTx(r.Context(), func(queries *db.Queries) error {
+			if _, err := queries.CreateTagRule(r.Context(), params); err != nil {
+				return fmt.Errorf("create tag rule: %w", err)
+			}
+			count, err = refreshAllNoteTags(r.Context(), logger, queries)
+			return err
+		})
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Created the rule for #%s and updated %d note(s).", params.Tag, count), sessionManager)
+		http.Redirect(w, r, "/tag-rules/", http.StatusSeeOther)
+	}
+}
+
+// deleteTagRule deletes a tagging rule and removes its tag from the notes that only had it from the rule
+func deleteTagRule(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 
-		if len(id) > 0 {
-			// Query for a single note if there is an id
-			_, err := queries.GetNote(r.Context(), id)
-			if errors.Is(err, pgx.ErrNoRows) {
-				clientError(w, http.StatusNotFound)
-				return
-			} else if err != nil {
-				serverError(w, r, err, logger, showTrace)
-				return
+		count := 0
+		err := queries.InTx(r.Context(), func(queries *db.Queries) error {
+			n, err := queries.DeleteTagRule(r.Context(), id)
+			if err != nil {
+				return fmt.Errorf("delete tag rule: %w", err)
+			} else if n == 0 {
+				return pgx.ErrNoRows
 			}
+			count, err = refreshAllNoteTags(r.Context(), logger, queries)
+			return err
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
 		}
 
-		// Parse out the note form data
-		form := noteForm{}
-		form.Title = r.FormValue("title")
-		form.Archive = r.FormValue("archive") != ""
-		form.Favorite = r.FormValue("favorite") != ""
-		form.Note = r.FormValue("note")
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Deleted the rule and updated %d note(s).", count), sessionManager)
+		http.Redirect(w, r, "/tag-rules/", http.StatusSeeOther)
+	}
+}
 
-		// Convert the value to time.Time
-		form.CreatedAt, err = time.ParseInLocation("2006-01-02T15:04", r.FormValue("created_at"), timeLocation)
+// savedSearches displays the saved searches with the number of notes each one matches
+func savedSearches(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		searches, err := queries.ListSavedSearches(r.Context())
 		if err != nil {
-			form.AddError("CreatedAt", "invalid date time")
-			form.CreatedAt = time.Now().In(timeLocation)
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+		counts, err := countSavedSearches(r.Context(), queries, searches)
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
 		}
 
-		// If title is blank, use the first line of the note content
-		if form.Title == "" {
-			before, _, found := strings.Cut(form.Note, "\n")
-			if found {
-				form.Title = strings.TrimSpace(before)
-			}
+		data := newTemplateData(r, sessionManager)
+		data["SavedSearches"] = counts
 
+		if err := render.Page(w, http.StatusOK, data, "savedSearches.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
 		}
+	}
+}
 
-		// Validate the form fields
-		form.Check("Title", validator.NotBlank(form.Title), "title is required")
-		form.Check("Note", validator.NotBlank(form.Note), "note content is required")
-		form.Check("CreatedAt", !form.CreatedAt.IsZero(), "must be a valid date time")
+// createSavedSearch saves the query string of a notes list search with a name
+func createSavedSearch(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
 
-		// Return the form data and re-render the form page if there are any errors
-		if form.HasErrors() {
-			// Create a new template data for a future response
-			data := newTemplateData(r, sessionManager)
-			data["Form"] = form
-			if err := render.Page(w, http.StatusUnprocessableEntity, data, "noteForm.tmpl"); err != nil {
-				serverError(w, r, err, logger, showTrace)
-				return
-			}
+		values, err := url.ParseQuery(r.PostForm.Get("query"))
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
 			return
 		}
+		params := db.CreateSavedSearchParams{
+			Name:   strings.TrimSpace(r.PostForm.Get("name")),
+			Query:  savedSearchQuery(values),
+			Pinned: r.PostForm.Get("pinned") != "",
+		}
+		searchURL := "/notes/search/?" + params.Query
 
-		switch {
-		case len(id) > 0:
-			// Update an existing Note
-			params := db.UpdateNoteParams{
-				ID:        id,
-				Title:     form.Title,
-				Note:      form.Note,
-				Archive:   form.Archive,
-				Favorite:  form.Favorite,
-				CreatedAt: form.CreatedAt,
-				Tags:      extractTags(form.Note),
-			}
-			logger.Debug("updating a note", "params", params)
-			note, err = queries.UpdateNote(r.Context(), params)
-			if err != nil {
-				serverError(w, r, err, logger, showTrace)
-				return
-			}
+		searches, err := queries.ListSavedSearches(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
 
-		default:
-			// Create a new note
+		// Searches that can't be parsed can't be counted or run
+		_, parseErr := searchParams(values)
 
-			// Create an ID for the note
-			id, err = db.GenerateID("n")
-			if err != nil {
-				serverError(w, r, err, logger, showTrace)
-				return
-			}
-			// Create a new note
-			params := db.CreateNoteParams{
-				ID:        id,
-				Title:     form.Title,
-				Note:      form.Note,
-				Favorite:  form.Favorite,
-				CreatedAt: form.CreatedAt,
-				Archive:   form.Archive,
-				Tags:      extractTags(form.Note),
+		v := validator.Validator{}
+		v.Check("Query", parseErr == nil, fmt.Sprintf("Fix the search before saving it: %v.", parseErr))
+		v.Check("Name", validator.NotBlank(params.Name), "Enter a name for the search.")
+		v.Check("Name", validator.MaxRunes(params.Name, 100), "Enter a name with 100 characters or less.")
+		for _, saved := range searches {
+			v.Check("Query", saved.Query != params.Query, fmt.Sprintf("This search is already saved as %q.", saved.Name))
+		}
+		if !v.Valid() {
+			for _, message := range v.Errors {
+				putFlashMessage(r, flashError, message, sessionManager)
 			}
-			logger.Debug("creating a note", "params", params)
-			note, err = queries.CreateNote(r.Context(), params)
-			if err != nil {
-				serverError(w, r, err, logger, showTrace)
-				return
+			http.Redirect(w, r, searchURL, http.StatusSeeOther)
+			return
+		}
+
+		// Create an ID for the saved search
+		id, err := db.GenerateID("ss")
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+		params.ID = id
+
+		if _, err := queries.CreateSavedSearch(r.Context(), params); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Saved the search %q.", params.Name), sessionManager)
+		http.Redirect(w, r, searchURL, http.StatusSeeOther)
+	}
+}
+
+// updateSavedSearch renames a saved search and pins or unpins it
+func updateSavedSearch(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		params := db.UpdateSavedSearchParams{
+			ID:     r.PathValue("id"),
+			Name:   strings.TrimSpace(r.PostForm.Get("name")),
+			Pinned: r.PostForm.Get("pinned") != "",
+		}
+		next := localRedirectURL(r.PostForm.Get("next"), "/searches/")
+
+		v := validator.Validator{}
+		v.Check("Name", validator.NotBlank(params.Name), "Enter a name for the search.")
+		v.Check("Name", validator.MaxRunes(params.Name, 100), "Enter a name with 100 characters or less.")
+		if !v.Valid() {
+			for _, message := range v.Errors {
+				putFlashMessage(r, flashError, message, sessionManager)
 			}
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
 		}
 
-		// Note created or updated successfully, redirect to view the note
-		url := fmt.Sprintf("/note/%v/", note.ID)
-		http.Redirect(w, r, url, http.StatusSeeOther)
+		saved, err := queries.UpdateSavedSearch(r.Context(), params)
+		if errors.Is(err, pgx.ErrNoRows) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Saved the search %q.", saved.Name), sessionManager)
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
+
+// deleteSavedSearch deletes a saved search. The notes it matched are not changed.
+func deleteSavedSearch(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		n, err := queries.DeleteSavedSearch(r.Context(), r.PathValue("id"))
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		} else if n == 0 {
+			clientError(w, http.StatusNotFound)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, "Deleted the saved search.", sessionManager)
+		http.Redirect(w, r, localRedirectURL(r.PostForm.Get("next"), "/searches/"), http.StatusSeeOther)
+	}
+}
+
+// listNotebooks displays the notebook tree with forms to create, rename, move and delete notebooks
+func listNotebooks(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Query for the notebooks
+		notebooks, err := queries.ListNotebooks(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Prepare template data
+		data := newTemplateData(r, sessionManager)
+		data["Notebooks"] = flattenNotebookTree(notebookTree(notebooks))
+
+		// Render the page
+		if err := render.Page(w, http.StatusOK, data, "notebooks.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+	}
+}
+
+// createNotebook creates a new notebook, optionally inside of another notebook
+func createNotebook(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Return Bad Request if the form data is not parseable
+		if err := r.ParseForm(); err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimSpace(r.FormValue("name"))
+		parentID := r.FormValue("parent_id")
+
+		// Query for the notebooks to check the parent notebook
+		notebooks, err := queries.ListNotebooks(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Validate the form fields
+		v := validator.Validator{}
+		v.Check("Name", validator.NotBlank(name), "Notebook name is required.")
+		v.Check("ParentID", parentID == "" || len(notebookPath(notebooks, parentID)) > 0, "Parent notebook does not exist.")
+		for _, message := range v.Errors {
+			putFlashMessage(r, flashError, message, sessionManager)
+		}
+		if v.HasErrors() {
+			http.Redirect(w, r, "/notebooks/", http.StatusSeeOther)
+			return
+		}
+
+		// Create an ID for the notebook
+		id, err := db.GenerateID("nb")
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		params := db.CreateNotebookParams{
+			ID:   id,
+			Name: name,
+		}
+		if parentID != "" {
+			params.ParentID = &parentID
+		}
+
+		logger.Debug("creating a notebook", "params", params)
+		if _, err := queries.CreateNotebook(r.Context(), params); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Created the %s notebook.", name), sessionManager)
+		http.Redirect(w, r, "/notebooks/", http.StatusSeeOther)
+	}
+}
+
+// updateNotebook renames a notebook and moves it to a different parent notebook
+func updateNotebook(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		// Return Bad Request if the form data is not parseable
+		if err := r.ParseForm(); err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimSpace(r.FormValue("name"))
+		parentID := r.FormValue("parent_id")
+
+		// Query for the notebooks to check the notebook and its new parent
+		notebooks, err := queries.ListNotebooks(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+		if len(notebookPath(notebooks, id)) == 0 {
+			clientError(w, http.StatusNotFound)
+			return
+		}
+
+		// Validate the form fields. A notebook can't be moved inside of itself.
+		v := validator.Validator{}
+		v.Check("Name", validator.NotBlank(name), "Notebook name is required.")
+		v.Check("ParentID", parentID == "" || len(notebookPath(notebooks, parentID)) > 0, "Parent notebook does not exist.")
+		v.Check("ParentID", parentID == "" || !notebookContains(notebooks, id, parentID), "A notebook can't be moved inside of itself.")
+		for _, message := range v.Errors {
+			putFlashMessage(r, flashError, message, sessionManager)
+		}
+		if v.HasErrors() {
+			http.Redirect(w, r, "/notebooks/", http.StatusSeeOther)
+			return
+		}
+
+		params := db.UpdateNotebookParams{
+			ID:   id,
+			Name: name,
+		}
+		if parentID != "" {
+			params.ParentID = &parentID
+		}
+
+		logger.Debug("updating a notebook", "params", params)
+		if _, err := queries.UpdateNotebook(r.Context(), params); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Saved the %s notebook.", name), sessionManager)
+		http.Redirect(w, r, "/notebooks/", http.StatusSeeOther)
+	}
+}
+
+// deleteNotebook deletes a notebook and moves its notes and notebooks up to the parent notebook
+func deleteNotebook(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		var notebook db.Notebook
+		err := queries.InTx(r.Context(), func(queries *db.Queries) error {
+			// Query for the notebook
+			var err error
+			notebook, err = queries.GetNotebook(r.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			// Move the notes and notebooks inside of the notebook before it's deleted
+			if err := queries.MoveNotebookNotes(r.Context(), db.MoveNotebookNotesParams{ParentID: notebook.ParentID, ID: id}); err != nil {
+				return fmt.Errorf("move notebook notes: %w", err)
+			}
+			if err := queries.MoveNotebookChildren(r.Context(), db.MoveNotebookChildrenParams{ParentID: notebook.ParentID, ID: id}); err != nil {
+				return fmt.Errorf("move notebook children: %w", err)
+			}
+
+			logger.Debug("deleting a notebook", "id", id)
+			if _, err := queries.DeleteNotebook(r.Context(), id); err != nil {
+				return fmt.Errorf("delete notebook: %w", err)
+			}
+			return nil
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Deleted the %s notebook. Its notes were moved up a level.", notebook.Name), sessionManager)
+		http.Redirect(w, r, "/notebooks/", http.StatusSeeOther)
+	}
+}
+
+// dailyNote redirects to the daily note for today or the date in the url path.
+// The daily note is created if it doesn't exist yet.
+func dailyNote(
+	logger *slog.Logger,
+	showTrace bool,
+	queries *db.Queries,
+	options dailyNoteOptions,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Use today's date in the time location unless there's a date in the url path
+		day := time.Now().In(timeLocation)
+		if value := r.PathValue("date"); value != "" {
+			date, err := time.ParseInLocation("2006-01-02", value, timeLocation)
+			if err != nil {
+				clientError(w, http.StatusNotFound)
+				return
+			}
+			day = time.Date(date.Year(), date.Month(), date.Day(), day.Hour(), day.Minute(), 0, 0, timeLocation)
+		}
+
+		// Query for the daily note or create it
+		note, err := queries.GetDailyNote(r.Context(), time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC))
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Debug("creating a daily note", "day", day)
+			note, err = createDailyNote(r.Context(), queries, options, day)
+		}
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("/note/%s/", note.ID), http.StatusSeeOther)
+	}
+}
+
+// viewNote displays a single note
+func viewNote(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Create a new template data file
+		data := newTemplateData(r, sessionManager)
+
+		// Check if there is an id value for the note
+		id := r.PathValue("id")
+
+		// Query for a single note
+		note, err := queries.GetNote(r.Context(), id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			noteNotFound(w, r, id, logger, showTrace, queries)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Query for the notes that link to this note
+		backlinks, err := queries.ListBacklinks(r.Context(), db.ListBacklinksParams{ID: note.ID, Title: note.Title})
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Query for the note attachments
+		noteAttachments, err := queries.ListNoteAttachments(r.Context(), note.ID)
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Query for the notes most like this one
+		related, err := queries.RelatedNotes(r.Context(), db.ListRelatedNotesParams{
+			ID:            note.ID,
+			MaxCandidates: 50,
+			Threshold:     float32(relatedThreshold),
+			MaxResults:    5,
+		})
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Query for the tag colors
+		tags, err := queries.ListTags(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Find the notebook the note is in
+		if note.NotebookID != nil {
+			notebooks, err := queries.ListNotebooks(r.Context())
+			if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+			data["NotebookPath"] = notebookPath(notebooks, *note.NotebookID)
+		}
+
+		// Link to the previous and next daily notes
+		if note.DailyDate != nil {
+			data["PrevDay"] = note.DailyDate.AddDate(0, 0, -1).Format("2006-01-02")
+			data["NextDay"] = note.DailyDate.AddDate(0, 0, 1).Format("2006-01-02")
+		}
+
+		// Add the note data to the template data map
+		data["Note"] = note
+		data["TagLabels"] = noteTagLabels(note, nil)
+		data["TagColors"] = tagColors(tags)
+		data["Backlinks"] = backlinks
+		data["RelatedNotes"] = related
+		data["Attachments"] = noteAttachments
+
+		// Choose print vs regular view
+		switch {
+		// Render the page without the base template
+		case strings.HasSuffix(r.URL.Path, "/print/"):
+			if err := render.NamedTemplate(w, http.StatusOK, data, "base", "printBase.tmpl", "pages/viewNote.tmpl"); err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+		default:
+			// Render the page
+			if err := render.Page(w, http.StatusOK, data, "viewNote.tmpl"); err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+
+		}
+	}
+}
+
+// deleteNote moves a note to the trash
+func deleteNote(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Check if there is an id value for the note
+		id := r.PathValue("id")
+
+		// Query for a single note
+		note, err := queries.GetNote(r.Context(), id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			noteNotFound(w, r, id, logger, showTrace, queries)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			// Create a new template data file
+			data := newTemplateData(r, sessionManager)
+			data["Note"] = note
+
+			// Render the page
+			if err := render.Page(w, http.StatusOK, data, "deleteNote.tmpl"); err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+			return
+		case http.MethodPost:
+			_, err := queries.TrashNote(r.Context(), id)
+			if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+
+			putFlashMessage(r, flashSuccess, fmt.Sprintf("Moved %q to the trash.", note.Title), sessionManager)
+			http.Redirect(w, r, "/notes/list/", http.StatusSeeOther)
+			return
+
+		default:
+			clientError(w, http.StatusNotFound)
+			return
+		}
+	}
+}
+
+// listTrash displays the notes in the trash
+func listTrash(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Query the database for the notes in the trash
+		notes, err := queries.ListTrashedNotes(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		data := newTemplateData(r, sessionManager)
+		data["Notes"] = notes
+
+		// Render the page
+		if err := render.Page(w, http.StatusOK, data, "trash.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+	}
+}
+
+// restoreTrashedNote moves a note out of the trash
+func restoreTrashedNote(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		count, err := queries.RestoreNote(r.Context(), id)
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		} else if count == 0 {
+			clientError(w, http.StatusNotFound)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, "Restored the note from the trash.", sessionManager)
+		http.Redirect(w, r, fmt.Sprintf("/note/%s/", id), http.StatusSeeOther)
+	}
+}
+
+// deleteTrashedNote permanently deletes a note in the trash
+func deleteTrashedNote(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+	attachments storage.Store,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		// Query for the note attachments before they're deleted with the note
+		noteAttachments, err := queries.ListNoteAttachments(r.Context(), id)
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		count, err := queries.DeleteTrashedNote(r.Context(), id)
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		} else if count == 0 {
+			clientError(w, http.StatusNotFound)
+			return
+		}
+
+		// Clean up the attachment files
+		ids := []string{}
+		for _, a := range noteAttachments {
+			ids = append(ids, a.ID)
+		}
+		deleteAttachmentFiles(r.Context(), logger, attachments, ids)
+
+		putFlashMessage(r, flashSuccess, "Deleted the note forever.", sessionManager)
+		http.Redirect(w, r, "/notes/trash/", http.StatusSeeOther)
+	}
+}
+
+// duplicateNotes lists the pairs of notes that are nearly the same
+func duplicateNotes(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	// maxPairs is the most pairs of notes in the report, and maxPairsPerNote is the most
+	// pairs of notes that one note is compared in
+	const (
+		maxPairs        = 100
+		maxPairsPerNote = 5
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		threshold, err := duplicateThreshold(r.URL.Query().Get("threshold"))
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		pairs, err := queries.DuplicateNotes(r.Context(), float32(threshold), db.ListDuplicateNotesParams{
+			MaxPerNote: maxPairsPerNote,
+			MaxResults: maxPairs,
+		})
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		data := newTemplateData(r, sessionManager)
+		data["Pairs"] = pairs
+		data["Threshold"] = threshold
+		data["Truncated"] = len(pairs) == maxPairs
+
+		if err := render.Page(w, http.StatusOK, data, "duplicates.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+	}
+}
+
+// mergeNotesPreview shows what a note looks like after another note is merged into it
+func mergeNotesPreview(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keepID, otherID := r.URL.Query().Get("keep"), r.URL.Query().Get("other")
+		if keepID == otherID {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		layout := r.URL.Query().Get("layout")
+		if !isMergeLayout(layout) {
+			layout = mergeLayouts[0].Name
+		}
+
+		// Query for both notes
+		keep, err := queries.GetNote(r.Context(), keepID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+		other, err := queries.GetNote(r.Context(), otherID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Get the automatic tagging rules
+		rules, err := queries.ListTagRules(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		text := mergeNoteText(layout, keep, other)
+		_, tags := mergeNoteTags(keep, other, text, rules)
+		createdAt := keep.CreatedAt
+		if other.CreatedAt.Before(createdAt) {
+			createdAt = other.CreatedAt
+		}
+
+		data := newTemplateData(r, sessionManager)
+		data["Keep"] = keep
+		data["Other"] = other
+		data["Layout"] = layout
+		data["Layouts"] = mergeLayouts
+		data["Text"] = text
+		data["Tags"] = tags
+		data["CreatedAt"] = createdAt
+		data["Favorite"] = keep.Favorite || other.Favorite
+
+		if err := render.Page(w, http.StatusOK, data, "mergeNotes.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+	}
+}
+
+// mergeNotes merges a note into another note in one transaction and redirects the merged
+// note's ID to the note it was merged into
+func mergeNotes(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		keepID, otherID := r.PostForm.Get("keep"), r.PostForm.Get("other")
+		layout, remove := r.PostForm.Get("layout"), r.PostForm.Get("remove")
+
+		v := validator.Validator{}
+		v.Check("other", keepID != otherID, "Choose two different notes to merge.")
+		v.Check("layout", isMergeLayout(layout), "Choose how to combine the notes.")
+		v.Check("remove", remove == "trash" || remove == "delete", "Choose whether to trash or delete the other note.")
+		if !v.Valid() {
+			for _, message := range v.Errors {
+				putFlashMessage(r, flashError, message, sessionManager)
+			}
+			http.Redirect(w, r, "/notes/duplicates/", http.StatusSeeOther)
+			return
+		}
+
+		var other db.Note
+		err := queries.InTx(r.Context(), func(queries *db.Queries) error {
+			keep, err := queries.GetNote(r.Context(), keepID)
+			if err != nil {
+				return err
+			}
+			other, err = queries.GetNote(r.Context(), otherID)
+			if err != nil {
+				return err
+			}
+			return mergeNote(r.Context(), queries, keep, other, layout, remove)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if errors.Is(err, errMergeConflict) {
+			// Show the preview again with the notes as they are now
+			putFlashMessage(r, flashError, "One of the notes changed while merging. Check the merge and try again.", sessionManager)
+			query := url.Values{"keep": {keepID}, "other": {otherID}, "layout": {layout}}
+			http.Redirect(w, r, "/notes/duplicates/merge/?"+query.Encode(), http.StatusSeeOther)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		logger.Info("merged notes", "note_id", keepID, "merged_id", otherID, "layout", layout, "remove", remove)
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Merged %q into this note.", other.Title), sessionManager)
+		http.Redirect(w, r, fmt.Sprintf("/note/%s/", keepID), http.StatusSeeOther)
+	}
+}
+
+// noteFormGet displays an editor for creating or updating notes
+func noteFormGet(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	type noteForm struct {
+		Title      string
+		Note       string
+		Favorite   bool
+		Archive    bool
+		CreatedAt  time.Time
+		NotebookID string
+		IsTemplate bool
+		RemindAt   *time.Time
+		ModifiedAt time.Time
+		Tags       string
+		validator.Validator
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := newTemplateData(r, sessionManager)
+		form := noteForm{
+			Title:      r.URL.Query().Get("title"),
+			Note:       "",
+			Favorite:   false,
+			Archive:    false,
+			CreatedAt:  time.Now().In(timeLocation),
+			NotebookID: r.URL.Query().Get("notebook"),
+		}
+
+		// Check if there is an id value in the url path
+		id := r.PathValue("id")
+
+		// Query for a single note if there is an id
+		if id != "" {
+			note, err := queries.GetNote(r.Context(), id)
+			if errors.Is(err, pgx.ErrNoRows) {
+				noteNotFound(w, r, id, logger, showTrace, queries)
+				return
+			} else if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+
+			// Query for the note attachments
+			noteAttachments, err := queries.ListNoteAttachments(r.Context(), id)
+			if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+
+			data["Note"] = note
+			data["Attachments"] = noteAttachments
+
+			// Fill in the form with the Note data
+			form = noteForm{
+				Title:     note.Title,
+				Note:      note.Note,
+				Favorite:  note.Favorite,
+				Archive:   note.Archive,
+				CreatedAt: note.CreatedAt,
+			}
+			if note.NotebookID != nil {
+				form.NotebookID = *note.NotebookID
+			}
+			form.IsTemplate = note.IsTemplate
+			form.ModifiedAt = note.ModifiedAt
+			form.Tags = strings.Join(note.ExplicitTags, ", ")
+			if note.RemindAt != nil {
+				remindAt := note.RemindAt.In(timeLocation)
+				form.RemindAt = &remindAt
+			}
+		} else if templateID := r.URL.Query().Get("template"); templateID != "" {
+			// Query for the template note to start the new note from
+			templateNote, err := queries.GetNote(r.Context(), templateID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				clientError(w, http.StatusNotFound)
+				return
+			} else if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+
+			// Fill in the form with the expanded template
+			now := time.Now()
+			if form.Title == "" {
+				form.Title = expandTemplate(templateNote.Title, "", now)
+			}
+			form.Note = expandTemplate(templateNote.Note, form.Title, now)
+			if form.NotebookID == "" && templateNote.NotebookID != nil {
+				form.NotebookID = *templateNote.NotebookID
+			}
+		}
+
+		// Each editor autosaves to its own draft, so editors in different tabs don't
+		// overwrite each other's drafts
+		draftID, err := db.GenerateID("d")
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Offer to restore the newest autosaved draft that is newer than the saved note, or
+		// restore the chosen draft and keep autosaving to it
+		var draftNoteID *string
+		if id != "" {
+			draftNoteID = &id
+		}
+		var draft db.NoteDraft
+		restoreID := r.URL.Query().Get("draft")
+		if restoreID != "" {
+			draft, err = queries.GetNoteDraft(r.Context(), db.GetNoteDraftParams{ID: restoreID, NoteID: draftNoteID})
+		} else {
+			draft, err = queries.GetLatestNoteDraft(r.Context(), draftNoteID)
+		}
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+		case err != nil:
+			serverError(w, r, err, logger, showTrace)
+			return
+		case !draft.ModifiedAt.After(form.ModifiedAt):
+			// The note was saved after the draft
+		case restoreID != "":
+			form.Title = draft.Title
+			form.Note = draft.Note
+			form.Tags = draft.Tags
+			draftID = draft.ID
+			data["Draft"] = draft
+			data["DraftRestored"] = true
+		default:
+			data["Draft"] = draft
+		}
+		data["DraftID"] = draftID
+
+		// Query for the templates to start a new note from
+		if id == "" {
+			templates, err := queries.ListTemplateNotes(r.Context())
+			if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+			data["Templates"] = templates
+		}
+
+		// Query for the notebooks to choose from
+		notebooks, err := queries.ListNotebooks(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Query for the tags to suggest
+		tagList, err := queries.GetTagsWithCounts(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Populate the Form Data
+		data["Form"] = form
+		data["Notebooks"] = flattenNotebookTree(notebookTree(notebooks))
+		data["TagList"] = tagList
+
+		// Render the page
+		if err := render.Page(w, http.StatusOK, data, "noteForm.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+	}
+}
+
+// importNote handles POST requests to insert a note
+func importNote(
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			// Set content type to plain text
+			w.Header().Set("Content-Type", "text/plain")
+
+			// Write a text response
+			fmt.Fprint(w, nosurf.Token(r))
+			return
+		}
+
+		// Return Bad Request if the form data is not parseable
+		err := r.ParseForm()
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		noteID := r.FormValue("note_id")
+		if noteID == "" {
+			fmt.Fprintln(w, "missing note_id")
+			clientError(w, http.StatusUnprocessableEntity)
+			return
+		}
+		title := r.FormValue("title")
+		if title == "" {
+			fmt.Fprintln(w, "missing title")
+			clientError(w, http.StatusUnprocessableEntity)
+			return
+		}
+		note := r.FormValue("note")
+		if note == "" {
+			fmt.Fprintln(w, "missing note")
+			clientError(w, http.StatusUnprocessableEntity)
+			return
+		}
+		archive := len(r.FormValue("archive")) > 0
+		favorite := len(r.FormValue("favorite")) > 0
+
+		// Convert the value to time.Time
+		createdAt, err := time.ParseInLocation(time.RFC3339, r.FormValue("created_at"), timeLocation)
+		if err != nil || createdAt.IsZero() {
+			fmt.Fprintln(w, "missing or invalid created_at")
+			clientError(w, http.StatusUnprocessableEntity)
+			return
+		}
+		// Convert the value to time.Time
+		modifiedAt, err := time.ParseInLocation(time.RFC3339, r.FormValue("modified_at"), timeLocation)
+		if err != nil || modifiedAt.IsZero() {
+			fmt.Fprintln(w, "missing or invalid modified_at")
+			clientError(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		// Get the automatic tagging rules
+		rules, err := queries.ListTagRules(r.Context())
+		if err != nil {
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "error importing %s: %s", noteID, err.Error())
+			return
+		}
+
+		params := db.ImportNoteParams{
+			ID:         noteID,
+			Title:      title,
+			Note:       note,
+			Archive:    archive,
+			Favorite:   favorite,
+			CreatedAt:  createdAt,
+			ModifiedAt: modifiedAt,
+			Tags:       ruleTags(noteTags(note, nil, false), rules, title, note),
+		}
+
+		// Save the note with its first revision and wiki links together
+		var n db.Note
+		err = queries.InTx(r.Context(), func(queries *db.Queries) error {
+			var err error
+			n, err = queries.ImportNote(r.Context(), params)
+			if err != nil {
+				return err
+			}
+			if err := snapshotNote(r.Context(), queries, n.ID); err != nil {
+				return err
+			}
+			return saveNoteLinks(r.Context(), queries, n.ID, n.Note)
+		})
+		if err != nil {
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "error importing %s: %s", noteID, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "created note: %v", n.ID)
+	}
+}
+
+// noteFormPost handles POST requests to update or create notes
+func noteFormPOST(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+	attachments storage.Store,
+) http.HandlerFunc {
+	type noteForm struct {
+		Title      string
+		Note       string
+		Favorite   bool
+		Archive    bool
+		CreatedAt  time.Time
+		NotebookID string
+		IsTemplate bool
+		RemindAt   *time.Time
+		ModifiedAt time.Time
+		Tags       string
+		validator.Validator
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		var note, existingNote db.Note
+
+		// Return Bad Request if the form data is not parseable.
+		// The form is multipart when it includes attachments.
+		r.Body = http.MaxBytesReader(w, r.Body, maxNoteFormSize)
+		if err = r.ParseMultipartForm(maxAttachmentSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				clientError(w, http.StatusRequestEntityTooLarge)
+				return
+			}
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		// Check if there is an id value in the url path
+		id := r.PathValue("id")
+
+		if len(id) > 0 {
+			// Query for a single note if there is an id
+			existingNote, err = queries.GetNote(r.Context(), id)
+			if errors.Is(err, pgx.ErrNoRows) {
+				noteNotFound(w, r, id, logger, showTrace, queries)
+				return
+			} else if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+		}
+
+		// Parse out the note form data
+		form := noteForm{}
+		form.Title = r.FormValue("title")
+		form.Archive = r.FormValue("archive") != ""
+		form.Favorite = r.FormValue("favorite") != ""
+		form.Note = r.FormValue("note")
+		form.NotebookID = r.FormValue("notebook_id")
+		form.IsTemplate = r.FormValue("is_template") != ""
+		form.Tags = r.FormValue("tags")
+
+		// Convert the value to time.Time
+		form.CreatedAt, err = time.ParseInLocation("2006-01-02T15:04", r.FormValue("created_at"), timeLocation)
+		if err != nil {
+			form.AddError("CreatedAt", "invalid date time")
+			form.CreatedAt = time.Now().In(timeLocation)
+		}
+
+		// The version of the note the edits were made to
+		if len(id) > 0 {
+			form.ModifiedAt, err = time.Parse(time.RFC3339Nano, r.FormValue("modified_at"))
+			if err != nil {
+				clientError(w, http.StatusBadRequest)
+				return
+			}
+		}
+
+		// A blank reminder clears the reminder
+		if value := r.FormValue("remind_at"); value != "" {
+			remindAt, err := time.ParseInLocation("2006-01-02T15:04", value, timeLocation)
+			if err != nil {
+				form.AddError("RemindAt", "invalid date time")
+			} else {
+				form.RemindAt = &remindAt
+			}
+		}
+
+		// If title is blank, use the first line of the note content
+		if form.Title == "" {
+			before, _, found := strings.Cut(form.Note, "\n")
+			if found {
+				form.Title = strings.TrimSpace(before)
+			}
+
+		}
+
+		// Validate the form fields
+		form.Check("Title", validator.NotBlank(form.Title), "title is required")
+		form.Check("Note", validator.NotBlank(form.Note), "note content is required")
+		form.Check("CreatedAt", !form.CreatedAt.IsZero(), "must be a valid date time")
+
+		// Check the tags set on the note directly
+		explicitTags := parseTags(form.Tags)
+		for _, tag := range explicitTags {
+			form.Check("Tags", validTag(tag), "tags can only have letters, numbers, dashes and / for nested tags")
+		}
+
+		// Check the notebook exists
+		notebooks, err := queries.ListNotebooks(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+		var notebookID *string
+		if form.NotebookID != "" {
+			notebookID = &form.NotebookID
+			form.Check("NotebookID", len(notebookPath(notebooks, form.NotebookID)) > 0, "notebook does not exist")
+		}
+
+		// Check the uploaded attachments
+		uploads := []attachmentUpload{}
+		if r.MultipartForm != nil {
+			for _, fh := range r.MultipartForm.File["attachments"] {
+				if fh.Size > maxAttachmentSize {
+					form.AddError("Attachments", fmt.Sprintf("%s is larger than the 20 MB limit", fh.Filename))
+					continue
+				}
+				upload, err := newAttachmentUpload(fh)
+				if err != nil {
+					serverError(w, r, err, logger, showTrace)
+					return
+				}
+				uploads = append(uploads, upload)
+			}
+		}
+
+		// Query for the tags to suggest if the form is shown again
+		tagList, err := queries.GetTagsWithCounts(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Return the form data and re-render the form page if there are any errors
+		if form.HasErrors() {
+			// Create a new template data for a future response
+			data := newTemplateData(r, sessionManager)
+			data["Form"] = form
+			data["Note"] = existingNote
+			data["DraftID"] = r.FormValue("draft_id")
+			data["Notebooks"] = flattenNotebookTree(notebookTree(notebooks))
+			data["TagList"] = tagList
+			if err := render.Page(w, http.StatusUnprocessableEntity, data, "noteForm.tmpl"); err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+			return
+		}
+
+		// renderConflict re-renders the form with the saved version of the note when it
+		// changed after the edits were started. Saving the form again overwrites the saved version.
+		renderConflict := func(saved db.Note) {
+			form.ModifiedAt = saved.ModifiedAt
+
+			data := newTemplateData(r, sessionManager)
+			data["Form"] = form
+			data["Note"] = saved
+			data["Conflict"] = saved
+			data["ConflictDiff"] = diff.Lines(saved.Note, form.Note)
+			data["DraftID"] = r.FormValue("draft_id")
+			data["Notebooks"] = flattenNotebookTree(notebookTree(notebooks))
+			data["TagList"] = tagList
+			if err := render.Page(w, http.StatusConflict, data, "noteForm.tmpl"); err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+		}
+
+		// Check the note wasn't changed since the edits were started
+		if len(id) > 0 && !existingNote.ModifiedAt.Equal(form.ModifiedAt) {
+			renderConflict(existingNote)
+			return
+		}
+
+		// Get the automatic tagging rules
+		rules, err := queries.ListTagRules(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Save the note, its wiki links and its attachments in one transaction
+		conflict := false
+		err = queries.InTx(r.Context(), func(queries *db.Queries) error {
+			switch {
+			case len(id) > 0:
+				// Update an existing Note
+				params := db.UpdateNoteParams{
+					ID:           id,
+					Title:        form.Title,
+					Note:         form.Note,
+					Archive:      form.Archive,
+					Favorite:     form.Favorite,
+					CreatedAt:    form.CreatedAt,
+					Tags:         ruleTags(noteTags(form.Note, explicitTags, existingNote.DailyDate != nil), rules, form.Title, form.Note),
+					NotebookID:   notebookID,
+					IsTemplate:   form.IsTemplate,
+					RemindAt:     form.RemindAt,
+					ModifiedAt:   form.ModifiedAt,
+					ExplicitTags: explicitTags,
+				}
+
+				// Save a revision of the note before it is overwritten
+				if existingNote.Title != form.Title || existingNote.Note != form.Note {
+					if err := snapshotNote(r.Context(), queries, id); err != nil {
+						return err
+					}
+				}
+
+				logger.Debug("updating a note", "params", params)
+				note, err = queries.UpdateNote(r.Context(), params)
+				if errors.Is(err, pgx.ErrNoRows) {
+					// The note was changed by another save after it was checked
+					conflict = true
+					return err
+				} else if err != nil {
+					return fmt.Errorf("update note: %w", err)
+				}
+
+			default:
+				// Create a new note
+
+				// Create an ID for the note
+				id, err = db.GenerateID("n")
+				if err != nil {
+					return err
+				}
+				// Create a new note
+				params := db.CreateNoteParams{
+					ID:           id,
+					Title:        form.Title,
+					Note:         form.Note,
+					Favorite:     form.Favorite,
+					CreatedAt:    form.CreatedAt,
+					Archive:      form.Archive,
+					Tags:         ruleTags(noteTags(form.Note, explicitTags, false), rules, form.Title, form.Note),
+					NotebookID:   notebookID,
+					IsTemplate:   form.IsTemplate,
+					RemindAt:     form.RemindAt,
+					ExplicitTags: explicitTags,
+				}
+				logger.Debug("creating a note", "params", params)
+				note, err = queries.CreateNote(r.Context(), params)
+				if err != nil {
+					return fmt.Errorf("create note: %w", err)
+				}
+			}
+
+			// Update the wiki links from the note
+			if err := saveNoteLinks(r.Context(), queries, note.ID, note.Note); err != nil {
+				return err
+			}
+
+			// Add the uploaded attachments
+			for _, upload := range uploads {
+				if err := createAttachment(r.Context(), queries, note.ID, upload); err != nil {
+					return err
+				}
+			}
+
+			// Remove the editor's autosaved draft now that the note is saved
+			draft := db.DeleteNoteDraftParams{ID: r.FormValue("draft_id")}
+			if id != "" {
+				draft.NoteID = &id
+			}
+			if err := queries.DeleteNoteDraft(r.Context(), draft); err != nil {
+				return fmt.Errorf("delete note draft: %w", err)
+			}
+			return nil
+		})
+		if conflict {
+			saved, err := queries.GetNote(r.Context(), id)
+			if errors.Is(err, pgx.ErrNoRows) {
+				clientError(w, http.StatusNotFound)
+				return
+			} else if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+			renderConflict(saved)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Store the uploaded files now that their attachments are saved, and add the stored
+		// images to the end of the note so it never links to an image that wasn't stored
+		text := note.Note
+		var storeErr error
+		for _, upload := range uploads {
+			if storeErr = storeAttachment(r.Context(), queries, attachments, upload); storeErr != nil {
+				break
+			}
+			if strings.HasPrefix(upload.ContentType, "image/") {
+				text = strings.TrimRight(text, "\n") + "\n\n" + attachmentMarkdown(upload.ID, upload.Filename, upload.ContentType)
+			}
+		}
+		if text != note.Note {
+			if _, err := queries.UpdateNoteText(r.Context(), db.UpdateNoteTextParams{ID: note.ID, Note: text}); err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+		}
+		if storeErr != nil {
+			serverError(w, r, storeErr, logger, showTrace)
+			return
+		}
+
+		// Note created or updated successfully, redirect to view the note
+		url := fmt.Sprintf("/note/%v/", note.ID)
+		http.Redirect(w, r, url, http.StatusSeeOther)
+	}
+}
+
+// saveNoteDraft autosaves the unsaved title, content and tags from a note editor
+func saveNoteDraft(
+	logger *slog.Logger,
+	showTrace bool,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := db.SaveNoteDraftParams{
+			ID:    r.FormValue("draft_id"),
+			Title: r.FormValue("title"),
+			Note:  r.FormValue("note"),
+			Tags:  r.FormValue("tags"),
+		}
+		if params.ID == "" {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		// Check the note exists when the draft is for an existing note
+		if id := r.PathValue("id"); id != "" {
+			if _, err := queries.GetNote(r.Context(), id); errors.Is(err, pgx.ErrNoRows) {
+				clientError(w, http.StatusNotFound)
+				return
+			} else if err != nil {
+				serverError(w, r, err, logger, showTrace)
+				return
+			}
+			params.NoteID = &id
+		}
+
+		if err := queries.SaveNoteDraft(r.Context(), params); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// deleteNoteDraft discards an autosaved draft for a note
+func deleteNoteDraft(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		draft, editURL := db.DeleteNoteDraftParams{ID: r.FormValue("draft_id")}, "/notes/new/"
+		if id := r.PathValue("id"); id != "" {
+			draft.NoteID, editURL = &id, fmt.Sprintf("/note/%s/edit/", id)
+		}
+
+		if err := queries.DeleteNoteDraft(r.Context(), draft); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, "Discarded the draft.", sessionManager)
+		http.Redirect(w, r, editURL, http.StatusSeeOther)
+	}
+}
+
+// noteHistory lists the revisions of a note and compares any two versions of the note
+func noteHistory(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		// Query for the note
+		note, err := queries.GetNote(r.Context(), id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			noteNotFound(w, r, id, logger, showTrace, queries)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Query for the note revisions
+		revisions, err := queries.ListNoteRevisions(r.Context(), id)
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Collect the text for each version of the note, "current" is the saved note
+		versions := map[string]db.NoteRevision{
+			"current": {ID: "current", NoteID: note.ID, Title: note.Title, Note: note.Note, Tags: note.Tags, CreatedAt: note.ModifiedAt},
+		}
+		for _, revision := range revisions {
+			versions[revision.ID] = revision
+		}
+
+		data := newTemplateData(r, sessionManager)
+		data["Note"] = note
+		data["Revisions"] = revisions
+
+		// Compare two versions if they were both chosen
+		from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		mode := r.URL.Query().Get("mode")
+		if from != "" && to != "" {
+			fromVersion, okFrom := versions[from]
+			toVersion, okTo := versions[to]
+			if !okFrom || !okTo {
+				clientError(w, http.StatusNotFound)
+				return
+			}
+
+			switch mode {
+			case "words":
+				data["Diff"] = diff.Words(fromVersion.Note, toVersion.Note)
+			default:
+				mode = "lines"
+				data["Diff"] = diff.Lines(fromVersion.Note, toVersion.Note)
+			}
+			data["From"] = fromVersion
+			data["To"] = toVersion
+		}
+		data["Mode"] = mode
+
+		// Render the page
+		if err := render.Page(w, http.StatusOK, data, "noteHistory.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+	}
+}
+
+// viewNoteRevision displays a single revision of a note
+func viewNoteRevision(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		// Query for the note
+		note, err := queries.GetNote(r.Context(), id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			noteNotFound(w, r, id, logger, showTrace, queries)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Query for the revision
+		params := db.GetNoteRevisionParams{
+			ID:     r.PathValue("revisionID"),
+			NoteID: id,
+		}
+		revision, err := queries.GetNoteRevision(r.Context(), params)
+		if errors.Is(err, pgx.ErrNoRows) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		data := newTemplateData(r, sessionManager)
+		data["Note"] = note
+		data["Revision"] = revision
+
+		// Render the page
+		if err := render.Page(w, http.StatusOK, data, "noteRevision.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+	}
+}
+
+// restoreNoteRevision replaces the note title and content with a previous revision
+func restoreNoteRevision(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		// Query for the note
+		note, err := queries.GetNote(r.Context(), id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			noteNotFound(w, r, id, logger, showTrace, queries)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Query for the revision
+		revision, err := queries.GetNoteRevision(r.Context(), db.GetNoteRevisionParams{
+			ID:     r.PathValue("revisionID"),
+			NoteID: id,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Get the automatic tagging rules
+		rules, err := queries.ListTagRules(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Overwrite the note with the revision
+		params := db.UpdateNoteParams{
+			ID:           id,
+			Title:        revision.Title,
+			Note:         revision.Note,
+			Archive:      note.Archive,
+			Favorite:     note.Favorite,
+			CreatedAt:    note.CreatedAt,
+			Tags:         ruleTags(noteTags(revision.Note, note.ExplicitTags, note.DailyDate != nil), rules, revision.Title, revision.Note),
+			NotebookID:   note.NotebookID,
+			IsTemplate:   note.IsTemplate,
+			RemindAt:     note.RemindAt,
+			ModifiedAt:   note.ModifiedAt,
+			ExplicitTags: note.ExplicitTags,
+		}
+		logger.Debug("restoring a note revision", "note_id", id, "revision_id", revision.ID)
+		err = queries.InTx(r.Context(), func(queries *db.Queries) error {
+			// Save the current version so the restore can be undone
+			if err := snapshotNote(r.Context(), queries, id); err != nil {
+				return err
+			}
+			if _, err := queries.UpdateNote(r.Context(), params); err != nil {
+				return err
+			}
+
+			// Update the wiki links from the restored note
+			return saveNoteLinks(r.Context(), queries, id, revision.Note)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			putFlashMessage(r, flashError, "The note changed while restoring the revision, please try again.", sessionManager)
+			http.Redirect(w, r, fmt.Sprintf("/note/%s/history/", id), http.StatusSeeOther)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, "Restored the note revision.", sessionManager)
+		http.Redirect(w, r, fmt.Sprintf("/note/%s/", id), http.StatusSeeOther)
+	}
+}
+
+// toggleTask checks or unchecks a checklist item in a note
+func toggleTask(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		next := localRedirectURL(r.FormValue("next"), fmt.Sprintf("/note/%s/", id))
+
+		// Query for the note
+		note, err := queries.GetNote(r.Context(), id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			noteNotFound(w, r, id, logger, showTrace, queries)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		line, err := strconv.Atoi(r.FormValue("line"))
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		// The line numbers are from the version of the note the page was loaded with
+		modifiedAt, err := time.Parse(time.RFC3339Nano, r.FormValue("modified_at"))
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		if !note.ModifiedAt.Equal(modifiedAt) {
+			putFlashMessage(r, flashError, "The note changed, reload and try again.", sessionManager)
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
+		}
+
+		// Rewrite the checklist line in the note
+		text, err := tasklist.Toggle(note.Note, line, r.FormValue("checked") != "")
+		if errors.Is(err, tasklist.ErrNotTask) {
+			// The note changed since the page was loaded
+			putFlashMessage(r, flashError, "The checklist item has changed, please try again.", sessionManager)
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Get the automatic tagging rules
+		rules, err := queries.ListTagRules(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		params := db.UpdateNoteParams{
+			ID:           id,
+			Title:        note.Title,
+			Note:         text,
+			Archive:      note.Archive,
+			Favorite:     note.Favorite,
+			CreatedAt:    note.CreatedAt,
+			Tags:         ruleTags(noteTags(text, note.ExplicitTags, note.DailyDate != nil), rules, note.Title, text),
+			NotebookID:   note.NotebookID,
+			IsTemplate:   note.IsTemplate,
+			RemindAt:     note.RemindAt,
+			ModifiedAt:   modifiedAt,
+			ExplicitTags: note.ExplicitTags,
+		}
+		logger.Debug("toggling a task", "note_id", id, "line", line)
+		err = queries.InTx(r.Context(), func(queries *db.Queries) error {
+			// Save a revision of the note before the checklist changes
+			if err := snapshotNote(r.Context(), queries, id); err != nil {
+				return err
+			}
+			_, err := queries.UpdateNote(r.Context(), params)
+			return err
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The note was changed by another save after it was checked
+			putFlashMessage(r, flashError, "The note changed, reload and try again.", sessionManager)
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
+
+// listTasks displays the open checklist items across all the notes that aren't archived
+func listTasks(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		notes, err := queries.ListNotes(r.Context())
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Due dates are compared against today in the configured time location
+		now := time.Now().In(timeLocation)
+
+		data := newTemplateData(r, sessionManager)
+		data["TaskGroups"] = openTasks(notes)
+		data["Today"] = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+		// Render the page
+		if err := render.Page(w, http.StatusOK, data, "tasks.tmpl"); err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+	}
+}
+
+// snoozeReminder pushes a note reminder back by one of the snooze durations
+func snoozeReminder(
+	logger *slog.Logger,
+	showTrace bool,
+	sessionManager *scs.SessionManager,
+	queries *db.Queries,
+) http.HandlerFunc {
+	// Durations a reminder can be snoozed for
+	durations := map[string]time.Duration{
+		"1h":   time.Hour,
+		"3h":   3 * time.Hour,
+		"24h":  24 * time.Hour,
+		"168h": 7 * 24 * time.Hour,
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		duration, ok := durations[r.FormValue("duration")]
+		if !ok {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		// Reset the reminder so it is sent again after the snooze
+		remindAt := time.Now().Add(duration)
+		count, err := queries.SnoozeReminder(r.Context(), db.SnoozeReminderParams{RemindAt: remindAt, ID: id})
+		if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		} else if count == 0 {
+			noteNotFound(w, r, id, logger, showTrace, queries)
+			return
+		}
+
+		putFlashMessage(r, flashSuccess, fmt.Sprintf("Snoozed the reminder until %s.", remindAt.In(timeLocation).Format("January 2, 2006, 3:04 pm")), sessionManager)
+		http.Redirect(w, r, fmt.Sprintf("/note/%s/", id), http.StatusSeeOther)
+	}
+}
+
+// viewAttachment streams an attachment file with its content type
+func viewAttachment(
+	logger *slog.Logger,
+	showTrace bool,
+	queries *db.Queries,
+	attachments storage.Store,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		// Query for the attachment details
+		attachment, err := queries.GetAttachment(r.Context(), id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+
+		// Open the attachment file
+		f, err := attachments.Open(r.Context(), attachment.ID)
+		if errors.Is(err, storage.ErrNotFound) {
+			clientError(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			serverError(w, r, err, logger, showTrace)
+			return
+		}
+		defer f.Close()
+
+		// Only display images, PDFs, and plain text in the browser, everything else is downloaded
+		disposition := "attachment"
+		switch mediaType, _, _ := mime.ParseMediaType(attachment.ContentType); {
+		case strings.HasPrefix(mediaType, "image/"), mediaType == "application/pdf", mediaType == "text/plain":
+			disposition = "inline"
+		}
+
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		http.ServeContent(w, r, attachment.Filename, attachment.CreatedAt, f)
 	}
 }
 
@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/sglmr/go-notes/db"
 	"github.com/sglmr/go-notes/internal/assert"
 	"github.com/sglmr/go-notes/internal/vcs"
@@ -116,7 +123,10 @@ func TestListNotes(t *testing.T) {
 	// Has the search form fields
 	assert.StringIn(t, `<form method="GET"`, response.body)
 	assert.StringIn(t, `<input type="text" name="q" id="q" placeholder="Search notes..." value="">`, response.body)
-	assert.StringIn(t, `<select name="tag" id="tag" aria-label="Select a tag...">`, response.body)
+	assert.StringIn(t, `<details id="tag" >`, response.body)
+	assert.StringIn(t, `<input type="checkbox" name="tag" value="fishing" >`, response.body)
+	assert.StringIn(t, `<input type="checkbox" name="notag" value="fishing" >`, response.body)
+	assert.StringIn(t, `<input type="radio" name="match" value="any" >`, response.body)
 	assert.StringIn(t, `<input type="checkbox" id="favorites" name="favorites"`, response.body)
 	assert.StringIn(t, `<input type="checkbox" id="archived" name="archived"`, response.body)
 
@@ -186,6 +196,93 @@ func TestDeleteNotePost(t *testing.T) {
 	// Validate the post doesn't exist anymore
 	response = ts.get(t, "/note/n_001/")
 	assert.Equal(t, http.StatusNotFound, response.statusCode)
+
+	// Validate the post is in the trash
+	response = ts.get(t, "/notes/trash/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "Weekend Plans", response.body)
+	assert.StringIn(t, `/notes/trash/n_001/restore/`, response.body)
+}
+
+func TestTrash(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/notes/trash/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// The trash starts out empty
+	ts.login(t)
+	response = ts.get(t, "/notes/trash/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "The trash is empty", response.body)
+
+	// Move two notes to the trash
+	for _, id := range []string{"n_003", "n_006"} {
+		if _, err := queries.TrashNote(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Trashed notes are not in the notes list
+	response = ts.get(t, "/notes/list/")
+	assert.StringNotIn(t, "Project Deadline", response.body)
+	assert.StringNotIn(t, "Book Recommendations", response.body)
+
+	// Trashed notes are in the trash
+	response = ts.get(t, "/notes/trash/")
+	assert.StringIn(t, "Project Deadline", response.body)
+	assert.StringIn(t, "Book Recommendations", response.body)
+	csrfToken := response.csrfToken(t)
+
+	// Restoring requires a csrf token
+	response = ts.post(t, "/notes/trash/n_003/restore/", url.Values{})
+	assert.Equal(t, http.StatusForbidden, response.statusCode)
+
+	// Restore a note from the trash
+	data := url.Values{}
+	data.Set("csrf_token", csrfToken)
+	response = ts.post(t, "/notes/trash/n_003/restore/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/note/n_003/", response.header.Get("Location"))
+
+	response = ts.get(t, "/note/n_003/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+
+	// Notes that aren't in the trash can't be restored or deleted forever
+	response = ts.post(t, "/notes/trash/n_003/restore/", data)
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+	response = ts.post(t, "/notes/trash/n_003/delete/", data)
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+
+	// Delete a note forever
+	response = ts.post(t, "/notes/trash/n_006/delete/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/notes/trash/", response.header.Get("Location"))
+
+	response = ts.get(t, "/notes/trash/")
+	assert.StringNotIn(t, "Book Recommendations", response.body)
+
+	// Purging only deletes notes that were trashed before the cutoff
+	if _, err := queries.TrashNote(context.Background(), "n_007"); err != nil {
+		t.Fatal(err)
+	}
+	count, err := queries.PurgeTrashedNotes(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(0), count)
+
+	count, err = queries.PurgeTrashedNotes(context.Background(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1), count)
 }
 
 func TestHome(t *testing.T) {
@@ -218,7 +315,7 @@ func TestNewNoteGET(t *testing.T) {
 	assert.Equal(t, http.StatusOK, response.statusCode)
 
 	// Has the form fields
-	assert.StringIn(t, `<form id="note-form" method="POST">`, response.body)
+	assert.StringIn(t, `<form id="note-form" method="POST" enctype="multipart/form-data">`, response.body)
 	assert.StringIn(t, `<input type="hidden" name="csrf_token" value="`, response.body)
 	assert.StringIn(t, `<input type="text" id="title" name="title"`, response.body)
 	assert.StringIn(t, `<input type="datetime-local" id="created_at" name="created_at"`, response.body)
@@ -352,7 +449,7 @@ func TestEditNoteGET(t *testing.T) {
 	assert.Equal(t, http.StatusOK, response.statusCode)
 
 	// Has the form fields
-	assert.StringIn(t, `<form id="note-form" method="POST">`, response.body)
+	assert.StringIn(t, `<form id="note-form" method="POST" enctype="multipart/form-data">`, response.body)
 	assert.StringIn(t, `<input type="hidden" name="csrf_token" value="`, response.body)
 	assert.StringIn(t, `<input type="text" id="title" name="title"`, response.body)
 	assert.StringIn(t, `<input type="datetime-local" id="created_at" name="created_at"`, response.body)
@@ -364,8 +461,10 @@ func TestEditNoteGET(t *testing.T) {
 	// Test the form has data from the fields
 	assert.StringIn(t, `New Recipe`, response.body)
 	assert.StringIn(t, `Found an amazing #recipe for pasta`, response.body)
-	assert.StringIn(t, `2025-01-20`, response.body)    // created_at is editable
-	assert.StringNotIn(t, `2025-02-01`, response.body) // modified_at is not editable
+	assert.StringIn(t, `2025-01-20`, response.body) // created_at is editable
+	// modified_at is not editable, it is only sent back to detect conflicting edits
+	assert.StringIn(t, `<input type="hidden" name="modified_at" value="`, response.body)
+	assert.StringNotIn(t, `id="modified_at"`, response.body)
 	assert.StringNotIn(t, `checked`, response.body)
 }
 
@@ -420,8 +519,9 @@ func TestEditNotePOST(t *testing.T) {
 	response = ts.post(t, url, data)
 	assert.Equal(t, http.StatusForbidden, response.statusCode)
 
-	// Add the csrf token and try again
+	// Add the csrf token and the version of the note being edited and try again
 	data.Set("csrf_token", csrfToken)
+	data.Set("modified_at", note.ModifiedAt.Format(time.RFC3339Nano))
 
 	// Test request OK with csrf token
 	response = ts.post(t, url, data)
@@ -498,3 +598,2056 @@ func TestTimeLocationGET(t *testing.T) {
 	assert.StringNotIn(t, "America/Los_Angeles", response.body)
 	assert.Equal(t, "America/New_York", timeLocation.String())
 }
+
+func TestNoteHistory(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/note/n_002/history/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// Test OK with login, there are no revisions yet
+	ts.login(t)
+	response = ts.get(t, "/note/n_002/history/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "No revisions yet", response.body)
+
+	// Edit the note to save a revision
+	response = ts.get(t, "/note/n_002/edit/")
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "Newer Recipe")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Found an amazing #recipe for pizza")
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// The previous version of the note is saved as a revision
+	revisions, err := queries.ListNoteRevisions(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(revisions))
+	assert.Equal(t, "New Recipe", revisions[0].Title)
+	assert.StringIn(t, "pasta carbonara", revisions[0].Note)
+
+	// The history page lists the revision
+	response = ts.get(t, "/note/n_002/history/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, fmt.Sprintf("/note/n_002/history/%s/", revisions[0].ID), response.body)
+
+	// Compare the revision with the current note
+	response = ts.get(t, fmt.Sprintf("/note/n_002/history/?from=%s&to=current&mode=words", revisions[0].ID))
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, `<ins style="color:green;">pizza</ins>`, response.body)
+
+	// Comparing an unknown revision is not found
+	response = ts.get(t, "/note/n_002/history/?from=r_nope&to=current")
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+
+	// View the revision and restore it
+	response = ts.get(t, fmt.Sprintf("/note/n_002/history/%s/", revisions[0].ID))
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "Restore this revision", response.body)
+
+	data = url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	response = ts.post(t, fmt.Sprintf("/note/n_002/history/%s/restore/", revisions[0].ID), data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/note/n_002/", response.header.Get("Location"))
+
+	// The note has the revision content and the overwritten version is saved
+	note, err := queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "New Recipe", note.Title)
+	assert.StringIn(t, "pasta carbonara", note.Note)
+
+	revisions, err = queries.ListNoteRevisions(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(revisions))
+}
+
+func TestWikiLinks(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// No backlinks to start with
+	ts.login(t)
+	response := ts.get(t, "/note/n_001/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringNotIn(t, "Linked from", response.body)
+
+	// Edit a note to link to another note and a note that doesn't exist
+	response = ts.get(t, "/note/n_002/edit/")
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "New Recipe")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Bring this on the trip in [[Weekend Plans]]. See [[Pasta Ideas]] too.")
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// The links are rendered in the note
+	response = ts.get(t, "/note/n_002/")
+	assert.StringIn(t, `<a href="/note/n_001/" class="wikilink">Weekend Plans</a>`, response.body)
+	assert.StringIn(t, `href="/notes/new/?title=Pasta+Ideas"`, response.body)
+
+	// The linked note shows the backlink
+	response = ts.get(t, "/note/n_001/")
+	assert.StringIn(t, "Linked from", response.body)
+	assert.StringIn(t, `<a href="/note/n_002/">New Recipe</a>`, response.body)
+
+	// Following a missing link fills in the title for a new note
+	response = ts.get(t, "/notes/new/?title=Pasta+Ideas")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, `value="Pasta Ideas"`, response.body)
+
+	// Saving the links again drops links that were only in code
+	err := queries.CreateNoteLinks(context.Background(), db.CreateNoteLinksParams{
+		SourceID: "n_003",
+		Targets:  []string{"Weekend Plans"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := refreshAllNoteLinks(context.Background(), queries)
+	assert.NoError(t, err)
+	assert.NotEqual(t, 0, count)
+	backlinks, err := queries.ListBacklinks(context.Background(), db.ListBacklinksParams{ID: "n_001", Title: "Weekend Plans"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(backlinks))
+}
+
+func TestAttachments(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/attachment/a_missing/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// Missing attachments are not found
+	ts.login(t)
+	response = ts.get(t, "/attachment/a_missing/")
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+
+	// Upload an image and a text file with a note
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	response = ts.get(t, "/note/n_002/edit/")
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "New Recipe")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Found an amazing #recipe for pasta carbonara")
+	files := map[string]string{"pasta.png": png, "shopping.txt": "eggs, cheese, pancetta"}
+	response = ts.postFiles(t, "/note/n_002/edit/", data, "attachments", files)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	attachments, err := queries.ListNoteAttachments(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(attachments))
+
+	// The image is added to the note and both files are listed
+	response = ts.get(t, "/note/n_002/")
+	assert.StringIn(t, `alt="pasta.png"`, response.body)
+	assert.StringIn(t, "shopping.txt", response.body)
+
+	// The edit form offers to insert the attachments
+	response = ts.get(t, "/note/n_002/edit/")
+	assert.StringIn(t, "Insert", response.body)
+
+	// Attachments are served with their content type
+	for _, a := range attachments {
+		response = ts.get(t, "/attachment/"+a.ID+"/")
+		assert.Equal(t, http.StatusOK, response.statusCode)
+		assert.Equal(t, a.ContentType, response.header.Get("Content-Type"))
+		assert.Equal(t, files[a.Filename], response.body)
+	}
+
+	// The attachments of a note in the trash are not served
+	if _, err := queries.TrashNote(context.Background(), "n_002"); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range attachments {
+		response = ts.get(t, "/attachment/"+a.ID+"/")
+		assert.Equal(t, http.StatusNotFound, response.statusCode)
+	}
+
+	// Deleting the note forever deletes the attachments
+	response = ts.get(t, "/notes/trash/")
+	data = url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	response = ts.post(t, "/notes/trash/n_002/delete/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	for _, a := range attachments {
+		_, err := queries.GetAttachmentData(context.Background(), a.ID)
+		assert.Equal(t, true, errors.Is(err, pgx.ErrNoRows))
+	}
+}
+
+func TestNotebooks(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/notebooks/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// There are no notebooks to start with
+	ts.login(t)
+	response = ts.get(t, "/notebooks/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "No notebooks yet", response.body)
+	csrfToken := response.csrfToken(t)
+
+	// Create a notebook and a notebook inside of it
+	data := url.Values{}
+	data.Set("csrf_token", csrfToken)
+	data.Set("name", "Home")
+	response = ts.post(t, "/notebooks/new/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	notebooks, err := queries.ListNotebooks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(notebooks))
+	homeID := notebooks[0].ID
+
+	data.Set("name", "Recipes")
+	data.Set("parent_id", homeID)
+	response = ts.post(t, "/notebooks/new/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// Notebooks need a name
+	data.Set("name", " ")
+	response = ts.post(t, "/notebooks/new/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	notebooks, err = queries.ListNotebooks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(notebooks))
+	recipesID := notebooks[1].ID
+
+	// Move a note into the inner notebook
+	response = ts.get(t, "/note/n_002/edit/")
+	assert.StringIn(t, "— Recipes", response.body)
+	data = url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "New Recipe")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Found an amazing #recipe for pasta carbonara")
+	data.Set("notebook_id", recipesID)
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// Notes can't be moved into a notebook that doesn't exist
+	data.Set("notebook_id", "nb_missing")
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusUnprocessableEntity, response.statusCode)
+
+	// Filtering by the outer notebook includes notes in the inner notebook
+	response = ts.get(t, "/notes/search/?notebook="+homeID)
+	assert.StringIn(t, "New Recipe", response.body)
+	assert.StringNotIn(t, "Weekend Plans", response.body)
+
+	// A notebook can't be moved inside of itself
+	data = url.Values{}
+	data.Set("csrf_token", csrfToken)
+	data.Set("name", "Home")
+	data.Set("parent_id", recipesID)
+	response = ts.post(t, "/notebook/"+homeID+"/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	notebook, err := queries.GetNotebook(context.Background(), homeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, notebook.ParentID == nil)
+
+	// Rename a notebook
+	data.Set("name", "Cooking")
+	data.Set("parent_id", homeID)
+	response = ts.post(t, "/notebook/"+recipesID+"/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	notebook, err = queries.GetNotebook(context.Background(), recipesID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Cooking", notebook.Name)
+
+	// Deleting a notebook moves its notes to the parent notebook
+	response = ts.post(t, "/notebook/"+recipesID+"/delete/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err := queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, homeID, *note.NotebookID)
+
+	response = ts.post(t, "/notebook/"+recipesID+"/delete/", data)
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+}
+
+func TestNoteTemplates(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	_ = db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// There are no templates to start with
+	ts.login(t)
+	response := ts.get(t, "/notes/new/")
+	assert.StringNotIn(t, "New from template", response.body)
+
+	// Flag a note as a template
+	response = ts.get(t, "/note/n_003/edit/")
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "Meeting Template")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "# {{title}}\n\n{{weekday}} {{date}} #meeting")
+	data.Set("is_template", "on")
+	response = ts.post(t, "/note/n_003/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// The template can be used from the new note page
+	response = ts.get(t, "/notes/new/")
+	assert.StringIn(t, "New from template", response.body)
+	assert.StringIn(t, `<option value="n_003">Meeting Template</option>`, response.body)
+
+	// Start a new note from the template
+	now := time.Now().In(timeLocation)
+	response = ts.get(t, "/notes/new/?template=n_003&title=Standup")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, `value="Standup"`, response.body)
+	assert.StringIn(t, "# Standup", response.body)
+	assert.StringIn(t, now.Weekday().String()+" "+now.Format("2006-01-02")+" #meeting", response.body)
+
+	// Missing templates are not found
+	response = ts.get(t, "/notes/new/?template=n_missing")
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+}
+
+func TestDailyNotes(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/notes/daily/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// Today's note is created and then reused
+	ts.login(t)
+	response = ts.get(t, "/notes/daily/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	today := response.header.Get("Location")
+
+	response = ts.get(t, "/notes/daily/")
+	assert.Equal(t, today, response.header.Get("Location"))
+
+	response = ts.get(t, today)
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, time.Now().In(timeLocation).Format("2006-01-02"), response.body)
+	assert.StringIn(t, "Previous day", response.body)
+
+	// Daily notes get the tags of the tagging rules that match
+	_, err := queries.CreateTagRule(context.Background(), db.CreateTagRuleParams{ID: "tr_daily", Kind: "title_prefix", Value: "2025-01", Tag: "january"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Open the note for another date
+	response = ts.get(t, "/notes/daily/2025-01-02/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.NotEqual(t, today, response.header.Get("Location"))
+
+	note, err := queries.GetDailyNote(context.Background(), time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/note/"+note.ID+"/", response.header.Get("Location"))
+	assert.Equal(t, "2025-01-02", note.Title)
+	assert.EqualSlices(t, []string{"daily", "january"}, note.Tags)
+
+	response = ts.get(t, "/note/"+note.ID+"/")
+	assert.StringIn(t, `href="/notes/daily/2025-01-01/"`, response.body)
+	assert.StringIn(t, `href="/notes/daily/2025-01-03/"`, response.body)
+
+	// Invalid dates are not found
+	response = ts.get(t, "/notes/daily/2025-02-30/")
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+
+	// Daily notes keep the reserved tag when they're edited
+	response = ts.get(t, "/note/"+note.ID+"/edit/")
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", note.Title)
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Went for a #walk")
+	response = ts.post(t, "/note/"+note.ID+"/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err = queries.GetNote(context.Background(), note.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"walk", "daily"}, note.Tags)
+
+	// Daily notes can be found with the reserved tag
+	response = ts.get(t, "/notes/search/?tag=daily")
+	assert.StringIn(t, "2025-01-02", response.body)
+	assert.StringNotIn(t, "Weekend Plans", response.body)
+}
+
+func TestNoteReminders(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/note/n_001/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// Set a reminder on a note
+	ts.login(t)
+	remindAt := time.Now().In(timeLocation).Add(-time.Hour).Truncate(time.Minute)
+	response = ts.get(t, "/note/n_001/edit/")
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "Weekend Plans")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("remind_at", remindAt.Format("2006-01-02T15:04"))
+	data.Set("note", "Go for a hike")
+	response = ts.post(t, "/note/n_001/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err := queries.GetNote(context.Background(), "n_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.RemindAt == nil {
+		t.Fatal("reminder was not saved")
+	}
+	assert.Equal(t, remindAt.Unix(), note.RemindAt.Unix())
+
+	response = ts.get(t, "/note/n_001/edit/")
+	assert.StringIn(t, remindAt.Format("2006-01-02T15:04"), response.body)
+
+	// Due reminders are only sent once
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	mailer := &testMailer{}
+	count, err := sendReminders(context.Background(), logger, queries, mailer, testEmail, "https://example.com", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, len(mailer.sent))
+
+	count, err = sendReminders(context.Background(), logger, queries, mailer, testEmail, "https://example.com", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, 1, len(mailer.sent))
+
+	response = ts.get(t, "/note/n_001/")
+	assert.StringIn(t, "Reminder sent", response.body)
+
+	// Snoozing the reminder sends it again later
+	data = url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("duration", "1h")
+	response = ts.post(t, "/note/n_001/snooze/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err = queries.GetNote(context.Background(), "n_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, note.ReminderSentAt == nil)
+	assert.Equal(t, true, note.RemindAt.After(time.Now()))
+
+	count, err = sendReminders(context.Background(), logger, queries, mailer, testEmail, "https://example.com", time.Now().Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// A reminder that fails is tried again later, until it has failed too many times
+	_, err = queries.SnoozeReminder(context.Background(), db.SnoozeReminderParams{RemindAt: time.Now(), ID: "n_001"})
+	assert.NoError(t, err)
+	mailer.err = errors.New("mail server is down")
+	now := time.Now().Add(time.Minute)
+	for attempts := int32(1); attempts <= maxReminderAttempts; attempts++ {
+		count, err = sendReminders(context.Background(), logger, queries, mailer, testEmail, "https://example.com", now)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		note, err = queries.GetNote(context.Background(), "n_001")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, attempts, note.ReminderAttempts)
+		if attempts < maxReminderAttempts {
+			assert.Equal(t, true, note.ReminderSentAt == nil)
+			assert.Equal(t, now.Add(reminderRetryDelay(attempts)).Unix(), note.ReminderRetryAt.Unix())
+
+			// The reminder isn't tried again before then
+			_, err = sendReminders(context.Background(), logger, queries, mailer, testEmail, "https://example.com", now)
+			assert.NoError(t, err)
+			note, err = queries.GetNote(context.Background(), "n_001")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, attempts, note.ReminderAttempts)
+			now = *note.ReminderRetryAt
+		}
+	}
+	assert.Equal(t, true, note.ReminderSentAt != nil)
+
+	// A reminder that was given up on isn't tried again
+	mailer.err = nil
+	count, err = sendReminders(context.Background(), logger, queries, mailer, testEmail, "https://example.com", now.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// Invalid snooze durations are rejected
+	data.Set("duration", "5m")
+	response = ts.post(t, "/note/n_001/snooze/", data)
+	assert.Equal(t, http.StatusBadRequest, response.statusCode)
+
+	// Clearing the reminder
+	response = ts.get(t, "/note/n_001/edit/")
+	data = url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "Weekend Plans")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Go for a hike")
+	response = ts.post(t, "/note/n_001/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err = queries.GetNote(context.Background(), "n_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, note.RemindAt == nil)
+}
+
+func TestTasks(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/tasks/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// Add a checklist to a note
+	ts.login(t)
+	response = ts.get(t, "/note/n_002/edit/")
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "New Recipe")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Shopping\n\n- [ ] Buy flour @due(2026-10-20)\n- [x] Buy eggs\n- [ ] Preheat oven")
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	before, err := queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The note page has checkboxes for the tasks
+	response = ts.get(t, "/note/n_002/")
+	assert.StringIn(t, `data-task-line="2"`, response.body)
+	assert.StringIn(t, `action="/note/n_002/task/"`, response.body)
+
+	// The tasks page lists the open tasks
+	response = ts.get(t, "/tasks/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "Buy flour", response.body)
+	assert.StringIn(t, "due 2026-10-20", response.body)
+	assert.StringIn(t, "Preheat oven", response.body)
+	assert.StringNotIn(t, "Buy eggs", response.body)
+
+	// Check off a task from the tasks page
+	data = url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("line", "2")
+	data.Set("checked", "true")
+	data.Set("next", "/tasks/")
+	response = ts.post(t, "/note/n_002/task/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/tasks/", response.header.Get("Location"))
+
+	note, err := queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Shopping\n\n- [x] Buy flour @due(2026-10-20)\n- [x] Buy eggs\n- [ ] Preheat oven", note.Note)
+	assert.Equal(t, true, note.ModifiedAt.After(before.ModifiedAt))
+
+	// The old version of the checklist was saved as a revision
+	revisions, err := queries.ListNoteRevisions(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, before.Note, revisions[0].Note)
+
+	// Checking off a task from a page loaded before the note changed goes back to the page
+	// with a message instead of changing the note
+	data.Set("line", "3")
+	response = ts.post(t, "/note/n_002/task/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/tasks/", response.header.Get("Location"))
+	response = ts.get(t, "/tasks/")
+	assert.StringIn(t, "The note changed, reload and try again.", response.body)
+
+	// Uncheck a task from the note page
+	response = ts.get(t, "/note/n_002/")
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("line", "3")
+	data.Set("checked", "")
+	data.Del("next")
+	response = ts.post(t, "/note/n_002/task/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/note/n_002/", response.header.Get("Location"))
+
+	note, err = queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Shopping\n\n- [x] Buy flour @due(2026-10-20)\n- [ ] Buy eggs\n- [ ] Preheat oven", note.Note)
+
+	// Lines that aren't tasks are not changed
+	data.Set("modified_at", note.ModifiedAt.Format(time.RFC3339Nano))
+	data.Set("line", "0")
+	response = ts.post(t, "/note/n_002/task/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err = queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Shopping\n\n- [x] Buy flour @due(2026-10-20)\n- [ ] Buy eggs\n- [ ] Preheat oven", note.Note)
+
+	// Invalid lines are a bad request
+	data.Set("line", "abc")
+	response = ts.post(t, "/note/n_002/task/", data)
+	assert.Equal(t, http.StatusBadRequest, response.statusCode)
+}
+
+func TestEditNoteConflict(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Open the same note in two tabs
+	ts.login(t)
+	response := ts.get(t, "/note/n_003/edit/")
+	first := url.Values{}
+	first.Set("csrf_token", response.csrfToken(t))
+	first.Set("modified_at", response.inputValue(t, "modified_at"))
+	first.Set("title", "Project Deadline")
+	first.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	first.Set("note", "Deadline moved to Friday")
+
+	second := url.Values{}
+	for key, values := range first {
+		second[key] = values
+	}
+	second.Set("note", "Deadline moved to Monday")
+
+	// The first save works
+	response = ts.post(t, "/note/n_003/edit/", first)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// The second save conflicts and doesn't overwrite the first
+	response = ts.post(t, "/note/n_003/edit/", second)
+	assert.Equal(t, http.StatusConflict, response.statusCode)
+	assert.StringIn(t, "after you started editing it", response.body)
+	assert.StringIn(t, "- Deadline moved to Friday", response.body)
+	assert.StringIn(t, "+ Deadline moved to Monday", response.body)
+
+	note, err := queries.GetNote(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Deadline moved to Friday", note.Note)
+
+	// Saving again from the conflict page replaces the saved version
+	second.Set("modified_at", response.inputValue(t, "modified_at"))
+	response = ts.post(t, "/note/n_003/edit/", second)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err = queries.GetNote(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Deadline moved to Monday", note.Note)
+
+	// Edits without the note version are rejected
+	second.Del("modified_at")
+	response = ts.post(t, "/note/n_003/edit/", second)
+	assert.Equal(t, http.StatusBadRequest, response.statusCode)
+}
+
+func TestNoteDrafts(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.post(t, "/note/n_002/draft/", url.Values{})
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// Autosave a draft of an existing note
+	ts.login(t)
+	response = ts.get(t, "/note/n_002/edit/")
+	csrfToken := response.csrfToken(t)
+	modifiedAt := response.inputValue(t, "modified_at")
+	draftID := response.inputValue(t, "draft_id")
+	noteID := "n_002"
+	otherDraftID := ts.get(t, "/note/n_002/edit/").inputValue(t, "draft_id")
+	assert.NotEqual(t, draftID, otherDraftID)
+	assert.StringIn(t, `fetch("\/note\/n_002\/draft\/"`, response.body)
+	assert.StringNotIn(t, `<section id="draft">`, response.body)
+
+	data := url.Values{}
+	data.Set("csrf_token", csrfToken)
+	data.Set("title", "New Recipe")
+	data.Set("note", "An unsaved change to the recipe")
+	data.Set("tags", "cooking, dessert")
+	response = ts.post(t, "/note/n_002/draft/", data)
+	assert.Equal(t, http.StatusBadRequest, response.statusCode)
+
+	data.Set("draft_id", draftID)
+	response = ts.post(t, "/note/n_002/draft/", data)
+	assert.Equal(t, http.StatusNoContent, response.statusCode)
+
+	// Drafts for notes that don't exist are not found
+	response = ts.post(t, "/note/n_missing/draft/", data)
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+
+	// Another editor autosaves to its own draft
+	otherData := url.Values{}
+	otherData.Set("csrf_token", csrfToken)
+	otherData.Set("draft_id", otherDraftID)
+	otherData.Set("title", "New Recipe")
+	otherData.Set("note", "Another unsaved change")
+	response = ts.post(t, "/note/n_002/draft/", otherData)
+	assert.Equal(t, http.StatusNoContent, response.statusCode)
+
+	draft, err := queries.GetNoteDraft(context.Background(), db.GetNoteDraftParams{ID: draftID, NoteID: &noteID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "An unsaved change to the recipe", draft.Note)
+	assert.Equal(t, "cooking, dessert", draft.Tags)
+
+	// The editor offers to restore the newest draft
+	response = ts.get(t, "/note/n_002/edit/")
+	assert.StringIn(t, "There is an unsaved draft of this note", response.body)
+	assert.StringIn(t, "?draft="+otherDraftID, response.body)
+	assert.StringNotIn(t, "Another unsaved change", response.body)
+
+	// Restoring a draft keeps autosaving to it
+	response = ts.get(t, "/note/n_002/edit/?draft="+draftID)
+	assert.StringIn(t, "Restored the unsaved draft", response.body)
+	assert.StringIn(t, "An unsaved change to the recipe", response.body)
+	assert.StringIn(t, "cooking, dessert", response.body)
+	assert.StringIn(t, `name="draft_id" value="`+draftID+`"`, response.body)
+
+	// Saving the note removes the editor's draft
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("modified_at", modifiedAt)
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	_, err = queries.GetNoteDraft(context.Background(), db.GetNoteDraftParams{ID: draftID, NoteID: &noteID})
+	assert.Equal(t, true, errors.Is(err, pgx.ErrNoRows))
+
+	// The other draft is older than the saved note now
+	response = ts.get(t, "/note/n_002/edit/")
+	assert.StringNotIn(t, `<section id="draft">`, response.body)
+
+	// Autosave a draft of a new note
+	response = ts.get(t, "/notes/new/")
+	newDraftID := response.inputValue(t, "draft_id")
+	data = url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("draft_id", newDraftID)
+	data.Set("title", "Half written")
+	data.Set("note", "Some thoughts")
+	response = ts.post(t, "/notes/new/draft/", data)
+	assert.Equal(t, http.StatusNoContent, response.statusCode)
+
+	draft, err = queries.GetNoteDraft(context.Background(), db.GetNoteDraftParams{ID: newDraftID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Half written", draft.Title)
+	assert.Equal(t, true, draft.NoteID == nil)
+
+	// A draft can't be moved to another note
+	response = ts.post(t, "/note/n_002/draft/", data)
+	assert.Equal(t, http.StatusNoContent, response.statusCode)
+	_, err = queries.GetNoteDraft(context.Background(), db.GetNoteDraftParams{ID: newDraftID, NoteID: &noteID})
+	assert.Equal(t, true, errors.Is(err, pgx.ErrNoRows))
+
+	response = ts.get(t, "/notes/new/")
+	assert.StringIn(t, "There is an unsaved draft of this note", response.body)
+
+	// Discard the draft
+	response = ts.post(t, "/notes/new/draft/delete/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/notes/new/", response.header.Get("Location"))
+
+	response = ts.get(t, "/notes/new/")
+	assert.StringNotIn(t, `<section id="draft">`, response.body)
+}
+
+func TestBulkNotes(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.post(t, "/notes/bulk/", url.Values{})
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/login/?next=%2Fnotes%2Fbulk%2F", response.header.Get("Location"))
+
+	// The notes list has checkboxes for bulk actions
+	ts.login(t)
+	response = ts.get(t, "/notes/list/")
+	assert.StringIn(t, `action="/notes/bulk/"`, response.body)
+	assert.StringIn(t, `<input type="checkbox" name="id" value="n_001" form="bulk-form"`, response.body)
+
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("next", "/notes/list/?tag=work")
+
+	// Unknown actions are rejected
+	data.Set("action", "explode")
+	data["id"] = []string{"n_001"}
+	response = ts.post(t, "/notes/bulk/", data)
+	assert.Equal(t, http.StatusBadRequest, response.statusCode)
+
+	// Archive the selected notes
+	data.Set("action", "archive")
+	data["id"] = []string{"n_001", "n_002", "n_002", "n_missing"}
+	response = ts.post(t, "/notes/bulk/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/notes/list/?tag=work", response.header.Get("Location"))
+	response = ts.get(t, "/notes/list/")
+	assert.StringIn(t, "Archived 2 notes.", response.body)
+
+	for _, id := range []string{"n_001", "n_002"} {
+		note, err := queries.GetNote(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, true, note.Archive)
+	}
+
+	// Unfavorite and unarchive them again
+	data.Set("action", "unfavorite")
+	response = ts.post(t, "/notes/bulk/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	data.Set("action", "unarchive")
+	response = ts.post(t, "/notes/bulk/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err := queries.GetNote(context.Background(), "n_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, false, note.Archive)
+	assert.Equal(t, false, note.Favorite)
+
+	// Tag names are checked
+	data.Set("action", "add-tag")
+	data.Set("tag", "not a tag")
+	response = ts.post(t, "/notes/bulk/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/notes/list/")
+	assert.StringIn(t, "Enter a tag with letters, numbers, dashes and / for nested tags.", response.body)
+
+	// Add a tag to the note text and tags
+	data.Set("tag", "#Later")
+	response = ts.post(t, "/notes/bulk/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/notes/list/")
+	assert.StringIn(t, "Added #later to 2 notes.", response.body)
+
+	note, err = queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringIn(t, "#later", note.Note)
+	assert.EqualSlices(t, []string{"recipe", "cooking", "later"}, note.Tags)
+
+	// Remove a tag from the notes that have it
+	data.Set("action", "remove-tag")
+	data.Set("tag", "fishing")
+	response = ts.post(t, "/notes/bulk/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/notes/list/")
+	assert.StringIn(t, "Removed #fishing from 1 note.", response.body)
+
+	note, err = queries.GetNote(context.Background(), "n_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringNotIn(t, "#fishing", note.Note)
+	assert.EqualSlices(t, []string{"outdoor", "later"}, note.Tags)
+
+	// Move the notes to the trash
+	data.Set("action", "trash")
+	response = ts.post(t, "/notes/bulk/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/notes/list/")
+	assert.StringIn(t, "Moved 2 notes to the trash.", response.body)
+	assert.StringNotIn(t, "Weekend Plans", response.body)
+}
+
+func TestRenameTag(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/tags/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// The tags page lists the tags with their counts
+	ts.login(t)
+	response = ts.get(t, "/tags/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, `<a href="/tags/meeting/">#meeting</a>`, response.body)
+	assert.StringIn(t, `action="/tag-rename/"`, response.body)
+
+	// Invalid renames are rejected
+	response = ts.get(t, "/tag-rename/?from=meeting&to=meeting")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/tags/", response.header.Get("Location"))
+
+	// Preview the notes that change
+	response = ts.get(t, "/tag-rename/?from=meeting&to=%23Meetings")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "Rename #meeting to #meetings", response.body)
+	assert.StringIn(t, "Project Deadline", response.body)
+	assert.StringNotIn(t, "Weekend Plans", response.body)
+
+	// Nothing changes until the rename is confirmed
+	note, err := queries.GetNote(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"work", "meeting"}, note.Tags)
+
+	// Rename the tag
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("from", "meeting")
+	data.Set("to", "meetings")
+	response = ts.post(t, "/tag-rename/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/tags/", response.header.Get("Location"))
+	response = ts.get(t, "/tags/")
+	assert.StringIn(t, "Renamed #meeting to #meetings in 1 note(s).", response.body)
+
+	note, err = queries.GetNote(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringIn(t, "Important #meetings scheduled", note.Note)
+	assert.EqualSlices(t, []string{"meetings", "work"}, note.Tags)
+
+	// Notes in the trash keep their tags and aren't listed
+	_, err = queries.ImportNote(context.Background(), db.ImportNoteParams{
+		ID:         "n_trashed",
+		Title:      "Old Work Note",
+		Note:       "Done #work",
+		CreatedAt:  time.Now(),
+		ModifiedAt: time.Now(),
+		Tags:       []string{"work"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queries.TrashNote(context.Background(), "n_trashed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merge a tag into an existing tag
+	response = ts.get(t, "/tag-rename/?from=work&to=meetings")
+	assert.StringIn(t, "Merge #work into #meetings", response.body)
+	assert.StringNotIn(t, "Old Work Note", response.body)
+	data.Set("from", "work")
+	response = ts.post(t, "/tag-rename/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/tags/")
+	assert.StringIn(t, "Renamed #work to #meetings in 1 note(s).", response.body)
+
+	trashed, err := queries.ListTrashedNotes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(trashed))
+	assert.Equal(t, "Done #work", trashed[0].Note)
+
+	note, err = queries.GetNote(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringIn(t, "for the #meetings project", note.Note)
+	assert.EqualSlices(t, []string{"meetings"}, note.Tags)
+
+	// A revision is saved before each change
+	revisions, err := queries.ListNoteRevisions(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(revisions))
+}
+
+func TestNestedTags(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Add nested tags to a note
+	ts.login(t)
+	response := ts.get(t, "/note/n_002/edit/")
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "New Recipe")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Dinner ideas #project/alpha #project/beta/soup")
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err := queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"project/alpha", "project/beta/soup"}, note.Tags)
+
+	// The tag tree has the parent tag with the rolled up count, which counts the note
+	// with two nested tags once
+	response = ts.get(t, "/notes/list/")
+	assert.StringIn(t, `value="project"`, response.body)
+	assert.StringIn(t, `value="project/beta/soup"`, response.body)
+	assert.StringIn(t, "project <small>(1)</small>", response.body)
+
+	// Filtering by a parent tag finds notes with nested tags
+	response = ts.get(t, "/notes/list/?tag=project")
+	assert.StringIn(t, "New Recipe", response.body)
+	assert.StringNotIn(t, "Weekend Plans", response.body)
+
+	response = ts.get(t, "/notes/list/?tag=project/beta")
+	assert.StringIn(t, "New Recipe", response.body)
+
+	// Filtering by a tag doesn't match other tags that start the same way
+	response = ts.get(t, "/notes/list/?tag=proj")
+	assert.StringNotIn(t, "New Recipe", response.body)
+}
+
+func TestUnicodeTags(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Add Unicode and mixed case tags to a note
+	ts.login(t)
+	response := ts.get(t, "/note/n_002/edit/")
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "New Recipe")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Dinner at the #Café with #日本語 food #ToDo #todo")
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err := queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"café", "日本語", "todo"}, note.Tags)
+
+	// The note shows the tags as they are written
+	response = ts.get(t, "/note/n_002/")
+	assert.StringIn(t, "#Café</a>", response.body)
+	assert.StringIn(t, "#ToDo</a>", response.body)
+
+	// Filtering ignores the case of the tag
+	response = ts.get(t, "/notes/list/?tag=TODO")
+	assert.StringIn(t, "New Recipe", response.body)
+	assert.StringNotIn(t, "Weekend Plans", response.body)
+
+	// Existing note tags are extracted again once
+	if _, err := queries.UpdateNoteTags(context.Background(), db.UpdateNoteTagsParams{ID: "n_002", Tags: []string{"Café"}}); err != nil {
+		t.Fatal(err)
+	}
+	ran, err := runDataMigration(context.Background(), queries, "test-tags", func(queries *db.Queries) error {
+		_, err := refreshAllNoteTags(context.Background(), slog.New(slog.DiscardHandler), queries)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, true, ran)
+
+	note, err = queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"café", "日本語", "todo"}, note.Tags)
+
+	ran, err = runDataMigration(context.Background(), queries, "test-tags", func(queries *db.Queries) error {
+		t.Error("the data migration ran twice")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, false, ran)
+}
+
+func TestExplicitTags(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// The note form has a tags field with suggestions
+	ts.login(t)
+	response := ts.get(t, "/note/n_002/edit/")
+	assert.StringIn(t, `<input type="text" id="tags" name="tags"`, response.body)
+	assert.StringIn(t, `<option value="recipe">`, response.body)
+
+	// Tags must be valid
+	data := url.Values{}
+	data.Set("csrf_token", response.csrfToken(t))
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("title", "New Recipe")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Pasta #recipe")
+	data.Set("tags", "ToDo, bad!")
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusUnprocessableEntity, response.statusCode)
+	assert.StringIn(t, "tags can only have letters, numbers, dashes and / for nested tags", response.body)
+
+	// Save the note with explicit tags
+	data.Set("tags", "ToDo, home #recipe")
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err := queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"ToDo", "home", "recipe"}, note.ExplicitTags)
+	assert.EqualSlices(t, []string{"recipe", "todo", "home"}, note.Tags)
+
+	// The explicit tags are shown on the note and in the form
+	response = ts.get(t, "/note/n_002/")
+	assert.StringIn(t, "#ToDo</a>", response.body)
+	response = ts.get(t, "/note/n_002/edit/")
+	assert.Equal(t, "ToDo, home, recipe", response.inputValue(t, "tags"))
+
+	// Explicit tags survive edits to the note text
+	data.Set("modified_at", response.inputValue(t, "modified_at"))
+	data.Set("note", "Pasta without hashtags")
+	response = ts.post(t, "/note/n_002/edit/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	note, err = queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"todo", "home", "recipe"}, note.Tags)
+
+	// Refreshing the tags keeps the explicit tags
+	_, err = refreshAllNoteTags(context.Background(), slog.New(slog.DiscardHandler), queries)
+	assert.NoError(t, err)
+
+	note, err = queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"todo", "home", "recipe"}, note.Tags)
+}
+
+func TestTagMetadata(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/tags/work/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	// A tag without metadata has a landing page with its notes
+	ts.login(t)
+	response = ts.get(t, "/tags/work/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "Project Deadline", response.body)
+	assert.StringNotIn(t, "Weekend Plans", response.body)
+
+	// Invalid colors are rejected
+	token := response.csrfToken(t)
+	data := url.Values{}
+	data.Set("csrf_token", token)
+	data.Set("description", "Things for **work**")
+	data.Set("color", "red")
+	response = ts.post(t, "/tags/work/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/tags/work/", response.header.Get("Location"))
+	response = ts.get(t, "/tags/work/")
+	assert.StringIn(t, "Choose a color for the tag.", response.body)
+
+	// Save a description, color and pin the tag
+	data.Set("color", "#3B82F6")
+	data.Set("pinned", "on")
+	response = ts.post(t, "/tags/work/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	tag, err := queries.GetTag(context.Background(), "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Things for **work**", tag.Description)
+	assert.Equal(t, "#3b82f6", tag.Color)
+	assert.Equal(t, true, tag.Pinned)
+
+	// The landing page shows the description and pinned tags are in the menu
+	response = ts.get(t, "/tags/work/")
+	assert.StringIn(t, "Saved #work.", response.body)
+	assert.StringIn(t, "<strong>work</strong>", response.body)
+	assert.StringIn(t, `<a href="/tags/work/" style="color:#3b82f6;">#work</a>`, response.body)
+
+	// Notes show the tag color
+	response = ts.get(t, "/note/n_003/")
+	assert.StringIn(t, "background-color:#3b82f633;", response.body)
+
+	// Renaming the tag moves its metadata
+	data = url.Values{}
+	data.Set("csrf_token", token)
+	data.Set("from", "work")
+	data.Set("to", "jobs")
+	response = ts.post(t, "/tag-rename/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	tag, err = queries.GetTag(context.Background(), "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "#3b82f6", tag.Color)
+	_, err = queries.GetTag(context.Background(), "work")
+	assert.Equal(t, true, errors.Is(err, pgx.ErrNoRows))
+
+	// Clear the color and unpin the tag
+	data = url.Values{}
+	data.Set("csrf_token", token)
+	data.Set("color", "#3b82f6")
+	data.Set("no_color", "on")
+	response = ts.post(t, "/tags/jobs/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	tag, err = queries.GetTag(context.Background(), "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "", tag.Color)
+	assert.Equal(t, false, tag.Pinned)
+
+	// Tags with the names of the tag management pages have landing pages too
+	response = ts.get(t, "/tags/rename/child/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "#rename/child", response.body)
+}
+
+func TestTagRules(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/tag-rules/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	ts.login(t)
+	response = ts.get(t, "/tag-rules/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "No rules yet", response.body)
+	token := response.csrfToken(t)
+
+	// Invalid rules are rejected
+	response = ts.get(t, "/tag-rules/dry-run/?kind=title_prefix&value=&tag=project")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/tag-rules/", response.header.Get("Location"))
+
+	// Notes in the trash aren't in the preview
+	_, err := queries.ImportNote(context.Background(), db.ImportNoteParams{
+		ID:         "n_trashed",
+		Title:      "Project Archive",
+		Note:       "Old plans",
+		CreatedAt:  time.Now(),
+		ModifiedAt: time.Now(),
+		Tags:       []string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queries.TrashNote(context.Background(), "n_trashed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Preview a new rule before it's created
+	response = ts.get(t, "/tag-rules/dry-run/?kind=title_prefix&value=project&tag=%23Project")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "The rule would add #Project to these 1 note(s)", response.body)
+	assert.StringIn(t, "Project Deadline", response.body)
+	assert.StringNotIn(t, "Weekend Plans", response.body)
+	assert.StringNotIn(t, "Project Archive", response.body)
+
+	// Create the rule and add the tag to the notes that match
+	before, err := queries.GetNote(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := url.Values{}
+	data.Set("csrf_token", token)
+	data.Set("kind", "title_prefix")
+	data.Set("value", "project")
+	data.Set("tag", "Project")
+	response = ts.post(t, "/tag-rules/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/tag-rules/")
+	assert.StringIn(t, "Created the rule for #Project and updated", response.body)
+
+	note, err := queries.GetNote(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"meeting", "work", "project"}, note.Tags)
+	assert.StringNotIn(t, "#Project", note.Note)
+
+	// Tags from rules don't edit the note
+	assert.Equal(t, true, before.ModifiedAt.Equal(note.ModifiedAt))
+	revisions, err := queries.ListNoteRevisions(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, len(revisions))
+
+	// The dry run doesn't list notes that already have the tag
+	response = ts.get(t, "/tag-rules/dry-run/")
+	assert.StringIn(t, "The rule wouldn&#39;t change any notes.", response.body)
+
+	// New notes get the tag when they are saved
+	data = url.Values{}
+	data.Set("csrf_token", token)
+	data.Set("title", "Project Kickoff")
+	data.Set("created_at", time.Now().In(timeLocation).Format("2006-01-02T15:04"))
+	data.Set("note", "Agenda #work")
+	response = ts.post(t, "/notes/new/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	newNoteID := strings.Split(response.header.Get("Location"), "/")[2]
+	note, err = queries.GetNote(context.Background(), newNoteID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"work", "project"}, note.Tags)
+
+	// Deleting the rule removes the tag from the notes
+	rules, err := queries.ListTagRules(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(rules))
+
+	data = url.Values{}
+	data.Set("csrf_token", token)
+	response = ts.post(t, fmt.Sprintf("/tag-rules/%s/delete/", rules[0].ID), data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/tag-rules/")
+	assert.StringIn(t, "Deleted the rule and updated", response.body)
+
+	note, err = queries.GetNote(context.Background(), "n_003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"meeting", "work"}, note.Tags)
+
+	// Deleting a missing rule is not found
+	response = ts.post(t, fmt.Sprintf("/tag-rules/%s/delete/", rules[0].ID), data)
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+
+	// A tag named rules has a landing page
+	response = ts.get(t, "/tags/rules/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "#rules", response.body)
+}
+
+func TestFullTextSearch(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	ts.login(t)
+
+	// Words are stemmed and don't need to be next to each other
+	response := ts.get(t, "/notes/search/?q=projects+timelines")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "Project Deadline", response.body)
+	assert.StringNotIn(t, "Weekend Plans", response.body)
+
+	// Matches are highlighted in a snippet instead of the whole note
+	assert.StringIn(t, "<mark>Project</mark>", response.body)
+	assert.StringNotIn(t, "<table>", response.body)
+
+	// Title matches rank above matches in the note
+	response = ts.get(t, "/notes/search/?q=recipe")
+	assert.StringIn(t, "New Recipe", response.body)
+	assert.StringIn(t, "Bread Experiment", response.body)
+	assert.Equal(t, true, strings.Index(response.body, "New Recipe") < strings.Index(response.body, "Bread Experiment"))
+
+	// Notes can still be found by ID
+	response = ts.get(t, "/notes/search/?q=n_003")
+	assert.StringIn(t, "Project Deadline", response.body)
+
+	// The search language can be changed
+	count, err := queries.SetSearchLanguage(context.Background(), "simple")
+	assert.NoError(t, err)
+	assert.NotEqual(t, int64(0), count)
+	t.Cleanup(func() {
+		queries.SetSearchLanguage(context.Background(), "english")
+	})
+
+	language := searchLanguage
+	searchLanguage = "simple"
+	t.Cleanup(func() { searchLanguage = language })
+	response = ts.get(t, "/notes/search/?q=projects+timelines")
+	assert.StringNotIn(t, "Project Deadline", response.body)
+
+	// Setting the same language again doesn't index the notes again
+	count, err = queries.SetSearchLanguage(context.Background(), "simple")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	_, err = queries.SetSearchLanguage(context.Background(), "klingon")
+	assert.Equal(t, true, err != nil)
+}
+
+func TestSearchOperators(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	ts.login(t)
+
+	tests := []struct {
+		name    string
+		q       string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "Tag",
+			q:       "tag:recipe",
+			want:    []string{"New Recipe", "Bread Experiment"},
+			notWant: []string{"Project Deadline"},
+		},
+		{
+			name:    "Excluded tag",
+			q:       "recipe -tag:cooking",
+			want:    []string{"Bread Experiment"},
+			notWant: []string{"New Recipe"},
+		},
+		{
+			name:    "Favorite",
+			q:       "tag:recipe is:favorite",
+			want:    []string{"Bread Experiment"},
+			notWant: []string{"New Recipe"},
+		},
+		{
+			name:    "Archived",
+			q:       "is:archived",
+			want:    []string{"Summer Vacation Ideas"},
+			notWant: []string{"Weekend Plans"},
+		},
+		{
+			name:    "Title phrase",
+			q:       `title:"project deadline"`,
+			want:    []string{"Project Deadline"},
+			notWant: []string{"Weekend Plans"},
+		},
+		{
+			name:    "Dates",
+			q:       "after:2025-02-01 before:2025-03-01",
+			want:    []string{"Project Deadline"},
+			notWant: []string{"New Recipe", "Bread Experiment"},
+		},
+		{
+			name:    "Excluded word",
+			q:       "recipe -sourdough",
+			want:    []string{"New Recipe"},
+			notWant: []string{"Bread Experiment"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := ts.get(t, "/notes/search/?q="+url.QueryEscape(tt.q))
+			assert.Equal(t, http.StatusOK, response.statusCode)
+			for _, want := range tt.want {
+				assert.StringIn(t, want, response.body)
+			}
+			for _, notWant := range tt.notWant {
+				assert.StringNotIn(t, notWant, response.body)
+			}
+		})
+	}
+
+	// Problems with the query are shown with the search form
+	response := ts.get(t, "/notes/search/?q="+url.QueryEscape(`bread "sourdough`))
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "missing closing quote at character 7", response.body)
+	assert.StringNotIn(t, "Bread Experiment", response.body)
+}
+
+func TestMultiTagFilter(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	ts.login(t)
+
+	// Notes need all the selected tags
+	response := ts.get(t, "/notes/list/?tag=recipe&tag=baking")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "Bread Experiment", response.body)
+	assert.StringNotIn(t, "New Recipe", response.body)
+	assert.StringIn(t, `<input type="checkbox" name="tag" value="baking" checked>`, response.body)
+
+	// Or any of them
+	response = ts.get(t, "/notes/list/?tag=cooking&tag=baking&match=any")
+	assert.StringIn(t, "Bread Experiment", response.body)
+	assert.StringIn(t, "New Recipe", response.body)
+	assert.StringNotIn(t, "Project Deadline", response.body)
+	assert.StringIn(t, `<input type="radio" name="match" value="any" checked>`, response.body)
+
+	// Excluded tags remove notes
+	response = ts.get(t, "/notes/list/?tag=recipe&notag=cooking")
+	assert.StringIn(t, "Bread Experiment", response.body)
+	assert.StringNotIn(t, "New Recipe", response.body)
+	assert.StringIn(t, `<input type="checkbox" name="notag" value="cooking" checked>`, response.body)
+	assert.StringIn(t, `aria-label="Remove #cooking"`, response.body)
+
+	// The tag counts are for the notes that match
+	response = ts.get(t, "/notes/list/?tag=recipe")
+	assert.StringIn(t, "recipe <small>(2)</small>", response.body)
+	assert.StringIn(t, "baking <small>(1)</small>", response.body)
+	assert.StringIn(t, "work <small>(0)</small>", response.body)
+}
+
+func TestSavedSearches(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	// Test unauthorized without login
+	response := ts.get(t, "/searches/")
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+
+	ts.login(t)
+	response = ts.get(t, "/searches/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "No saved searches yet", response.body)
+	token := response.csrfToken(t)
+
+	// A search can be saved from the notes list
+	response = ts.get(t, "/notes/search/?q=&tag=recipe&favorites=on")
+	assert.StringIn(t, "Save this search", response.body)
+	assert.StringIn(t, `<input type="hidden" name="query" value="favorites=on&amp;tag=recipe">`, response.body)
+
+	data := url.Values{}
+	data.Set("csrf_token", token)
+	data.Set("name", "Favorite recipes")
+	data.Set("query", "favorites=on&tag=recipe")
+	data.Set("pinned", "on")
+	response = ts.post(t, "/searches/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/notes/search/?favorites=on&tag=recipe", response.header.Get("Location"))
+
+	// The same search can't be saved twice
+	data.Set("name", "Again")
+	response = ts.post(t, "/searches/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/searches/")
+	assert.StringIn(t, "This search is already saved as &#34;Favorite recipes&#34;.", response.body)
+
+	// Searches that can't be parsed can't be saved
+	data.Set("name", "Broken")
+	data.Set("query", "q=%22bread")
+	response = ts.post(t, "/searches/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/searches/")
+	assert.StringIn(t, "Fix the search before saving it: missing closing quote at character 1.", response.body)
+	assert.StringNotIn(t, "Broken", response.body)
+
+	// Pinned searches are in the nav with a live count
+	response = ts.get(t, "/notes/list/")
+	assert.StringIn(t, `<a href="/notes/search/?favorites=on&amp;tag=recipe">Favorite recipes <small>(1)</small></a>`, response.body)
+
+	if err := queries.FavoriteNote(context.Background(), "n_002"); err != nil {
+		t.Fatal(err)
+	}
+	response = ts.get(t, "/notes/list/")
+	assert.StringIn(t, "Favorite recipes <small>(2)</small>", response.body)
+
+	// The notes list shows when its search is saved
+	response = ts.get(t, "/notes/search/?tag=recipe&favorites=on&q=")
+	assert.StringIn(t, "Saved as <strong>Favorite recipes</strong>", response.body)
+
+	searches, err := queries.ListSavedSearches(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(searches))
+	id := searches[0].ID
+
+	// Rename and unpin the search
+	data = url.Values{}
+	data.Set("csrf_token", token)
+	data.Set("name", "Recipes to make")
+	data.Set("next", "/notes/search/?favorites=on&tag=recipe")
+	response = ts.post(t, "/searches/"+id+"/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/notes/search/?favorites=on&tag=recipe", response.header.Get("Location"))
+
+	response = ts.get(t, "/searches/")
+	assert.StringIn(t, "Recipes to make", response.body)
+	assert.StringNotIn(t, "Recipes to make <small>", response.body)
+
+	// Names are required
+	data.Set("name", " ")
+	response = ts.post(t, "/searches/"+id+"/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	response = ts.get(t, "/searches/")
+	assert.StringIn(t, "Enter a name for the search.", response.body)
+
+	// Delete the search
+	data = url.Values{}
+	data.Set("csrf_token", token)
+	response = ts.post(t, "/searches/"+id+"/delete/", data)
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/searches/", response.header.Get("Location"))
+	response = ts.get(t, "/searches/")
+	assert.StringIn(t, "No saved searches yet", response.body)
+
+	response = ts.post(t, "/searches/"+id+"/delete/", data)
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+}
+
+func TestNotesPages(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	ts.login(t)
+
+	// noteIDs returns the IDs of the notes on a page, in order
+	noteIDs := func(body string) []string {
+		ids := []string{}
+		for _, match := range regexp.MustCompile(`name="id" value="([^"]+)"`).FindAllStringSubmatch(body, -1) {
+			ids = append(ids, match[1])
+		}
+		return ids
+	}
+	// nextPage returns the link to the next page
+	nextPage := func(body string) string {
+		match := regexp.MustCompile(`href="([^"]+)">Next page`).FindStringSubmatch(body)
+		if match == nil {
+			t.Fatal("no next page link")
+		}
+		return html.UnescapeString(match[1])
+	}
+
+	for _, sort := range []string{"created", "modified", "title"} {
+		t.Run(sort, func(t *testing.T) {
+			// The first page has the page size of notes and the number of all of them
+			response := ts.get(t, "/notes/list/?size=10&sort="+sort)
+			assert.Equal(t, http.StatusOK, response.statusCode)
+			assert.StringIn(t, "<strong>13 note(s)</strong>", response.body)
+			assert.StringNotIn(t, "First page", response.body)
+			first := noteIDs(response.body)
+			assert.Equal(t, 10, len(first))
+
+			// The next page has the rest of the notes
+			response = ts.get(t, nextPage(response.body))
+			assert.Equal(t, http.StatusOK, response.statusCode)
+			assert.StringIn(t, "First page", response.body)
+			assert.StringNotIn(t, "Next page", response.body)
+			second := noteIDs(response.body)
+			assert.Equal(t, 3, len(second))
+
+			for _, id := range second {
+				assert.Equal(t, false, slices.Contains(first, id))
+			}
+		})
+	}
+
+	// Notes can be sorted by title
+	response := ts.get(t, "/notes/list/?sort=title")
+	assert.Equal(t, true, strings.Index(response.body, "Bread Experiment") < strings.Index(response.body, "Weekend Plans"))
+	response = ts.get(t, "/notes/list/?sort=created")
+	assert.Equal(t, true, strings.Index(response.body, "Weekend Plans") < strings.Index(response.body, "Bread Experiment"))
+
+	// The compact list shows a plain text excerpt of the notes
+	response = ts.get(t, "/notes/list/?compact=on")
+	assert.StringIn(t, "Q1 Project Timeline Important #meeting scheduled for next week.", response.body)
+	assert.StringNotIn(t, "<table>", response.body)
+
+	// Bad cursors are rejected
+	response = ts.get(t, "/notes/list/?after=%21%21")
+	assert.Equal(t, http.StatusBadRequest, response.statusCode)
+}
+
+func TestRelatedNotes(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	ts.login(t)
+
+	// Add a note like the bread experiment
+	_, err := queries.CreateNote(context.Background(), db.CreateNoteParams{
+		ID:        "n_bread",
+		Title:     "Bread Experiment Two",
+		Note:      "Trying another sourdough #recipe today. My #baking skills have improved a lot since I started practicing weekly.",
+		CreatedAt: time.Now(),
+		Tags:      []string{"recipe", "baking"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Similar notes are related and others aren't
+	response := ts.get(t, "/note/n_013/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "Related notes", response.body)
+	assert.StringIn(t, `<a href="/note/n_bread/">Bread Experiment Two</a>`, response.body)
+	assert.StringNotIn(t, `href="/note/n_001/"`, response.body)
+
+	// A lower threshold relates more notes
+	relatedThreshold = 0.01
+	defer func() { relatedThreshold = 0.3 }()
+	response = ts.get(t, "/note/n_013/")
+	assert.StringIn(t, `href="/note/n_001/"`, response.body)
+	relatedThreshold = 0.3
+
+	// Notes in the trash aren't related
+	if _, err := queries.TrashNote(context.Background(), "n_bread"); err != nil {
+		t.Fatal(err)
+	}
+	response = ts.get(t, "/note/n_013/")
+	assert.StringNotIn(t, `href="/note/n_bread/"`, response.body)
+}
+
+func TestDuplicateNotes(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	ts.login(t)
+
+	// Import a copy of the bread experiment
+	_, err := queries.CreateNote(context.Background(), db.CreateNoteParams{
+		ID:        "n_dup",
+		Title:     "Bread Experiment",
+		Note:      "Trying a new sourdough #recipe tomorrow. My #baking skills have improved a lot since I started practicing weekly. #imported",
+		CreatedAt: time.Now(),
+		Tags:      []string{"recipe", "baking", "imported"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The copy is listed with the original
+	response := ts.get(t, "/notes/duplicates/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, `href="/notes/duplicates/merge/?keep=n_013&amp;other=n_dup"`, response.body)
+	assert.StringNotIn(t, `other=n_001"`, response.body)
+
+	// Short notes with the same text are found even though their IDs differ
+	for _, id := range []string{"n_short_a", "n_short_b"} {
+		_, err := queries.CreateNote(context.Background(), db.CreateNoteParams{
+			ID:        id,
+			Title:     "Milk",
+			Note:      "eggs",
+			CreatedAt: time.Now(),
+			Tags:      []string{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	response = ts.get(t, "/notes/duplicates/?threshold=0.9")
+	assert.StringIn(t, `keep=n_short_a&amp;other=n_short_b"`, response.body)
+
+	response = ts.get(t, "/notes/duplicates/?threshold=2")
+	assert.Equal(t, http.StatusBadRequest, response.statusCode)
+
+	// Preview keeping the copy
+	response = ts.get(t, "/notes/duplicates/merge/?keep=n_dup&other=n_013&layout=keep")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "Favorite: Yes", response.body)
+	token := response.csrfToken(t)
+
+	// A note can't be merged into itself
+	response = ts.post(t, "/notes/duplicates/merge/", url.Values{
+		"csrf_token": {token},
+		"keep":       {"n_dup"},
+		"other":      {"n_dup"},
+		"layout":     {"keep"},
+		"remove":     {"trash"},
+	})
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/notes/duplicates/", response.header.Get("Location"))
+
+	// Link to the original by ID and by title
+	_, err = queries.CreateNote(context.Background(), db.CreateNoteParams{
+		ID:        "n_links",
+		Title:     "Baking links",
+		Note:      "See [[n_013|the experiment]] and [[bread experiment]], not `[[n_013]]`.",
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = queries.CreateNoteLinks(context.Background(), db.CreateNoteLinksParams{
+		SourceID: "n_links",
+		Targets:  []string{"n_013", "bread experiment"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Merge the original into the copy
+	original, err := queries.GetNote(context.Background(), "n_013")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response = ts.post(t, "/notes/duplicates/merge/", url.Values{
+		"csrf_token": {token},
+		"keep":       {"n_dup"},
+		"other":      {"n_013"},
+		"layout":     {"keep"},
+		"remove":     {"trash"},
+	})
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	assert.Equal(t, "/note/n_dup/", response.header.Get("Location"))
+
+	// The copy has the earliest created time, the favorite and all the tags
+	merged, err := queries.GetNote(context.Background(), "n_dup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, merged.CreatedAt.Equal(original.CreatedAt))
+	assert.Equal(t, true, merged.Favorite)
+	assert.EqualSlices(t, []string{"recipe", "baking", "imported"}, merged.Tags)
+
+	// The original is in the trash and redirects to the copy
+	_, err = queries.GetNote(context.Background(), "n_013")
+	assert.Equal(t, true, errors.Is(err, pgx.ErrNoRows))
+	response = ts.get(t, "/note/n_013/")
+	assert.Equal(t, http.StatusMovedPermanently, response.statusCode)
+	assert.Equal(t, "/note/n_dup/", response.header.Get("Location"))
+	response = ts.get(t, "/note/n_013/print/")
+	assert.Equal(t, "/note/n_dup/print/", response.header.Get("Location"))
+	response = ts.get(t, "/note/n_013/edit/")
+	assert.Equal(t, "/note/n_dup/edit/", response.header.Get("Location"))
+	response = ts.get(t, "/note/n_013/history/")
+	assert.Equal(t, "/note/n_dup/history/", response.header.Get("Location"))
+	response = ts.get(t, "/note/n_013/delete/")
+	assert.Equal(t, "/note/n_dup/delete/", response.header.Get("Location"))
+	response = ts.get(t, "/note/n_013/history/r_missing/")
+	assert.Equal(t, "/note/n_dup/history/r_missing/", response.header.Get("Location"))
+
+	// Forms for the original are posted again to the copy
+	response = ts.post(t, "/note/n_013/task/", url.Values{"csrf_token": {token}, "line": {"1"}})
+	assert.Equal(t, http.StatusPermanentRedirect, response.statusCode)
+	assert.Equal(t, "/note/n_dup/task/", response.header.Get("Location"))
+	response = ts.post(t, "/note/n_013/edit/", url.Values{"csrf_token": {token}})
+	assert.Equal(t, http.StatusPermanentRedirect, response.statusCode)
+	assert.Equal(t, "/note/n_dup/edit/", response.header.Get("Location"))
+
+	// The links to the original point at the copy, except for the one in code
+	linking, err := queries.GetNote(context.Background(), "n_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "See [[n_dup|the experiment]] and [[n_dup|bread experiment]], not `[[n_013]]`.", linking.Note)
+	backlinks, err := queries.ListBacklinks(context.Background(), db.ListBacklinksParams{ID: "n_dup", Title: "Bread Experiment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(backlinks))
+
+	// Merge the copy into another note and delete it, so both IDs redirect to that note
+	response = ts.post(t, "/notes/duplicates/merge/", url.Values{
+		"csrf_token": {token},
+		"keep":       {"n_002"},
+		"other":      {"n_dup"},
+		"layout":     {"sections"},
+		"remove":     {"delete"},
+	})
+	assert.Equal(t, http.StatusSeeOther, response.statusCode)
+	merged, err = queries.GetNote(context.Background(), "n_002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringIn(t, "## Bread Experiment\n\nTrying a new sourdough", merged.Note)
+	assert.Equal(t, true, merged.Favorite)
+	for _, id := range []string{"n_013", "n_dup"} {
+		response = ts.get(t, "/note/"+id+"/")
+		assert.Equal(t, "/note/n_002/", response.header.Get("Location"))
+	}
+	response = ts.get(t, "/note/n_missing/")
+	assert.Equal(t, http.StatusNotFound, response.statusCode)
+}
+
+func TestImportNote(t *testing.T) {
+	// Create a new test server
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Create a new database connection for queries
+	queries := db.NewTestDatabase(t, context.Background(), os.Getenv("NOTES_TEST_DB_DSN"), false)
+
+	ts.login(t)
+	response := ts.get(t, "/import/")
+	assert.Equal(t, http.StatusOK, response.statusCode)
+
+	// Import a note
+	data := url.Values{}
+	data.Set("csrf_token", response.body)
+	data.Set("note_id", "n_imported")
+	data.Set("title", "Imported Note")
+	data.Set("note", "Brought over from the old app #imported")
+	data.Set("created_at", "2024-01-02T15:04:05Z")
+	data.Set("modified_at", "2024-01-03T15:04:05Z")
+	response = ts.post(t, "/import/", data)
+	assert.Equal(t, http.StatusOK, response.statusCode)
+	assert.StringIn(t, "created note: n_imported", response.body)
+
+	note, err := queries.GetNote(context.Background(), "n_imported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualSlices(t, []string{"imported"}, note.Tags)
+
+	// The imported version is the first revision of the note
+	revisions, err := queries.ListNoteRevisions(context.Background(), "n_imported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(revisions))
+	assert.Equal(t, note.Note, revisions[0].Note)
+}
@@ -6,6 +6,7 @@ import (
 	"html"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
@@ -21,6 +22,7 @@ import (
 	"github.com/alexedwards/scs/v2/memstore"
 	"github.com/sglmr/go-notes/db"
 	"github.com/sglmr/go-notes/internal/email"
+	"github.com/sglmr/go-notes/internal/storage"
 )
 
 const (
@@ -57,7 +59,7 @@ func newTestServer(t *testing.T) *testServer {
 	// Create a test mailer (io.Discard)
 	mailer := email.NewLogMailer(logger)
 
-	handler := newServer(logger, false, mailer, testEmail, testPasswordHash, &sync.WaitGroup{}, sessionManager, queries)
+	handler := newServer(logger, false, mailer, testEmail, testPasswordHash, &sync.WaitGroup{}, sessionManager, queries, storage.NewDBStore(queries), dailyNoteOptions{TitleFormat: "2006-01-02", Body: "# {{title}}\n\n"})
 
 	// Initialize a new test server
 	ts := httptest.NewTLSServer(handler)
@@ -173,6 +175,63 @@ func (ts *testServer) post(t *testing.T, path string, data url.Values) testRespo
 	}
 }
 
+// postFiles issues a multipart POST request with form data and files and returns a testResponse object
+//   - 'path' is the relative url path, like "/about/"
+//   - 'files' maps a file name to its contents for the 'field' form field
+func (ts *testServer) postFiles(t *testing.T, path string, data url.Values, field string, files map[string]string) testResponse {
+	// Build the multipart form body
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	for key, values := range data {
+		for _, value := range values {
+			if err := mw.WriteField(key, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for name, contents := range files {
+		fw, err := mw.CreateFormFile(field, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(fw, contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a new http POST request.
+	request, err := http.NewRequest(http.MethodPost, ts.URL+path, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Header.Set("Content-Type", mw.FormDataContentType())
+
+	// Send the POST request.
+	response, err := ts.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the response body from the request.
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = bytes.TrimSpace(body)
+
+	// Return a testResponse object
+	return testResponse{
+		statusCode: response.StatusCode,
+		header:     response.Header,
+		body:       string(body),
+	}
+}
+
 // login will log a user in for testing
 func (ts *testServer) login(t *testing.T) {
 	// Get the login page form to capture the csrf token
@@ -212,3 +271,34 @@ func (ts *testServer) logout(t *testing.T) {
 		t.Fatal("could not log out")
 	}
 }
+
+// testMailer records the emails sent during a test. Sending fails with err when it is set.
+type testMailer struct {
+	mu   sync.Mutex
+	sent []any
+	err  error
+}
+
+// Send records the email data instead of sending an email
+func (m *testMailer) Send(recipient string, replyTo string, data any, templates ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, data)
+	return nil
+}
+
+// inputValue extracts and returns the value of a named form input from a testResponse html body
+func (tr testResponse) inputValue(t *testing.T, name string) string {
+	t.Helper()
+
+	inputRX := regexp.MustCompile(`<input[^>]* name="` + regexp.QuoteMeta(name) + `"[^>]* value="([^"]*)"`)
+
+	matches := inputRX.FindStringSubmatch(tr.body)
+	if len(matches) < 2 {
+		t.Fatalf("no %s input found in body", name)
+	}
+	return html.UnescapeString(matches[1])
+}
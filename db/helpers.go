@@ -2,6 +2,7 @@ package db
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -12,6 +13,7 @@ import (
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/sglmr/go-notes/assets"
@@ -95,6 +97,104 @@ func MigrateDown(conn *pgxpool.Pool) error {
 	return nil
 }
 
+// InTx runs fn with a Queries that uses a database transaction. The transaction is committed
+// when fn returns nil and rolled back when it returns an error. Calling InTx inside of a
+// transaction uses a savepoint.
+func (q *Queries) InTx(ctx context.Context, fn func(*Queries) error) error {
+	beginner, ok := q.db.(interface {
+		Begin(context.Context) (pgx.Tx, error)
+	})
+	if !ok {
+		return errors.New("database connection does not support transactions")
+	}
+
+	tx, err := beginner.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(q.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SetSearchLanguage sets the text search configuration, like english or simple, that notes are
+// indexed with for full text search. Notes indexed with a different configuration are indexed
+// again. It returns the number of notes that were indexed again. Nothing changes when the
+// language is already the one notes are indexed with.
+func (q *Queries) SetSearchLanguage(ctx context.Context, language string) (int64, error) {
+	var count int64
+	err := q.InTx(ctx, func(q *Queries) error {
+		// Check that the language is a text search configuration
+		var config string
+		if err := q.db.QueryRow(ctx, "select $1::regconfig::text", language).Scan(&config); err != nil {
+			return fmt.Errorf("text search language %q: %w", language, err)
+		}
+
+		// Check whether new notes are already indexed with the language. The default is
+		// only set together with indexing the existing notes again.
+		var current bool
+		err := q.db.QueryRow(ctx, `select coalesce(column_default = format('%L::regconfig', $1::text), false)
+from information_schema.columns
+where table_schema = current_schema()
+    and table_name = 'notes'
+    and column_name = 'search_language'`, config).Scan(&current)
+		if err != nil {
+			return fmt.Errorf("get default search language: %w", err)
+		} else if current {
+			return nil
+		}
+
+		// Index new notes with the language. The column default can't be a query parameter.
+		alter := fmt.Sprintf(
+			"alter table notes alter column search_language set default '%s'::regconfig",
+			strings.ReplaceAll(config, "'", "''"),
+		)
+		if _, err := q.db.Exec(ctx, alter); err != nil {
+			return fmt.Errorf("set default search language: %w", err)
+		}
+
+		count, err = q.UpdateSearchLanguage(ctx, config)
+		return err
+	})
+	return count, err
+}
+
+// RelatedNotes returns the notes most like a note with a score of at least the threshold.
+// The threshold is also the trigram similarity the notes' text needs to be found by the
+// index, so a higher threshold is faster.
+func (q *Queries) RelatedNotes(ctx context.Context, arg ListRelatedNotesParams) ([]ListRelatedNotesRow, error) {
+	var notes []ListRelatedNotesRow
+	err := q.InTx(ctx, func(q *Queries) error {
+		if err := q.SetSimilarityThreshold(ctx, arg.Threshold); err != nil {
+			return fmt.Errorf("set similarity threshold: %w", err)
+		}
+
+		var err error
+		notes, err = q.ListRelatedNotes(ctx, arg)
+		return err
+	})
+	return notes, err
+}
+
+// DuplicateNotes returns the pairs of notes with a trigram similarity of at least the
+// threshold, the most similar pairs first
+func (q *Queries) DuplicateNotes(ctx context.Context, threshold float32, arg ListDuplicateNotesParams) ([]ListDuplicateNotesRow, error) {
+	var pairs []ListDuplicateNotesRow
+	err := q.InTx(ctx, func(q *Queries) error {
+		if err := q.SetSimilarityThreshold(ctx, threshold); err != nil {
+			return fmt.Errorf("set similarity threshold: %w", err)
+		}
+
+		var err error
+		pairs, err = q.ListDuplicateNotes(ctx, arg)
+		return err
+	})
+	return pairs, err
+}
+
 // GenerateID makes up a unique ID with a prefix in the format prefix_RandomBase58ID.
 func GenerateID(prefix string) (string, error) {
 	// Validate prefix is
@@ -8,15 +8,88 @@ import (
 	"time"
 )
 
+type Attachment struct {
+	ID          string
+	NoteID      string
+	Filename    string
+	ContentType string
+	Size        int64
+	CreatedAt   time.Time
+}
+
+type AttachmentDatum struct {
+	AttachmentID string
+	Data         []byte
+}
+
+type DataMigration struct {
+	Name      string
+	AppliedAt time.Time
+}
+
 type Note struct {
+	ID               string
+	Title            string
+	Note             string
+	Archive          bool
+	Favorite         bool
+	CreatedAt        time.Time
+	ModifiedAt       time.Time
+	Tags             []string
+	DeletedAt        *time.Time
+	NotebookID       *string
+	IsTemplate       bool
+	DailyDate        *time.Time
+	RemindAt         *time.Time
+	ReminderSentAt   *time.Time
+	ExplicitTags     []string
+	SearchLanguage   string
+	ReminderAttempts int32
+	ReminderRetryAt  *time.Time
+}
+
+type NoteDraft struct {
 	ID         string
+	NoteID     *string
 	Title      string
 	Note       string
-	Archive    bool
-	Favorite   bool
-	CreatedAt  time.Time
 	ModifiedAt time.Time
-	Tags       []string
+	Tags       string
+}
+
+type NoteLink struct {
+	SourceID string
+	Target   string
+}
+
+type NoteRedirect struct {
+	OldID     string
+	NoteID    string
+	CreatedAt time.Time
+}
+
+type NoteRevision struct {
+	ID        string
+	NoteID    string
+	Title     string
+	Note      string
+	Tags      []string
+	CreatedAt time.Time
+}
+
+type Notebook struct {
+	ID        string
+	ParentID  *string
+	Name      string
+	CreatedAt time.Time
+}
+
+type SavedSearch struct {
+	ID        string
+	Name      string
+	Query     string
+	Pinned    bool
+	CreatedAt time.Time
 }
 
 type Session struct {
@@ -25,6 +98,22 @@ type Session struct {
 	Expiry time.Time
 }
 
+type Tag struct {
+	Name        string
+	Description string
+	Color       string
+	Pinned      bool
+	ModifiedAt  time.Time
+}
+
+type TagRule struct {
+	ID        string
+	Kind      string
+	Value     string
+	Tag       string
+	CreatedAt time.Time
+}
+
 type TagSummary struct {
 	TagName   interface{}
 	NoteCount int64
@@ -2,24 +2,33 @@
 select *
 from notes
 where id = $1
+    and deleted_at is null
 limit 1;
 -- name: ListNotes :many
 select *
 from notes
 where archive != TRUE
+    and deleted_at is null
 order by created_at desc;
 -- name: ListAllNotes :many
 select *
 from notes;
+-- name: ListActiveNotes :many
+select *
+from notes
+where deleted_at is null
+order by created_at desc;
 -- name: ListFavoriteNotes :many
 select *
 from notes
 where favorite = TRUE
+    and deleted_at is null
 order by modified_at desc;
 -- name: ListArchivedNotes :many
 select *
 from notes
 where archive = TRUE
+    and deleted_at is null
 order by created_at desc;
 -- name: CreateNote :one
 insert into notes (
@@ -30,9 +39,13 @@ insert into notes (
         favorite,
         created_at,
         modified_at,
-        tags
+        tags,
+        notebook_id,
+        is_template,
+        remind_at,
+        explicit_tags
     )
-values ($1, $2, $3, $4, $5, $6, NOW(), $7)
+values ($1, $2, $3, $4, $5, $6, NOW(), $7, $8, $9, $10, $11)
 returning *;
 -- name: UpdateNote :one
 update notes
@@ -42,8 +55,28 @@ set title = $2,
     favorite = $5,
     created_at = $6,
     tags = $7,
+    notebook_id = $8,
+    is_template = $9,
+    remind_at = $10,
+    reminder_sent_at = CASE
+        WHEN remind_at IS DISTINCT
+        FROM $10 THEN NULL
+        ELSE reminder_sent_at
+    END,
+    reminder_attempts = CASE
+        WHEN remind_at IS DISTINCT
+        FROM $10 THEN 0
+        ELSE reminder_attempts
+    END,
+    reminder_retry_at = CASE
+        WHEN remind_at IS DISTINCT
+        FROM $10 THEN NULL
+        ELSE reminder_retry_at
+    END,
+    explicit_tags = $12,
     modified_at = NOW()
 where id = $1
+    and modified_at = $11
 returning *;
 -- name: UpdateNoteTags :one
 update notes
@@ -51,31 +84,46 @@ set tags = $2,
     modified_at = NOW()
 where id = $1
 returning *;
+-- name: SetNoteTags :execrows
+update notes
+set tags = note_tags.tags
+from jsonb_to_recordset(@notes::jsonb) as note_tags(id text, tags text [], modified_at timestamptz)
+where notes.id = note_tags.id
+    and notes.modified_at = note_tags.modified_at;
+-- name: UpdateNoteExplicitTags :exec
+update notes
+set explicit_tags = $2
+where id = $1;
 -- name: DeleteNote :exec
 delete from notes
 where id = $1;
--- name: SearchNotes :many
-SELECT *
-FROM notes
-WHERE (
-        @query::text = ''
-        OR ('id' || ' ' || title || ' ' || note) ILIKE '%' || @query::text || '%'
-    )
-    AND (
-        @tags::text [] = '{""}' -- This matches the Go []string{""}
-        OR @tags::text [] = '{}'
-        OR tags @> @tags::text []
-    )
-    AND (archive = @archived::bool)
-    AND (
-        favorite = @favorites::bool
-        OR @favorites::bool = FALSE
-    )
-ORDER BY created_at DESC;
+-- name: TrashNote :execrows
+update notes
+set deleted_at = NOW()
+where id = $1
+    and deleted_at is null;
+-- name: RestoreNote :execrows
+update notes
+set deleted_at = NULL
+where id = $1
+    and deleted_at is not null;
+-- name: DeleteTrashedNote :execrows
+delete from notes
+where id = $1
+    and deleted_at is not null;
+-- name: ListTrashedNotes :many
+select *
+from notes
+where deleted_at is not null
+order by deleted_at desc;
+-- name: PurgeTrashedNotes :execrows
+delete from notes
+where deleted_at < @before::timestamptz;
 -- name: FindNotesWithTags :many
 SELECT *
 FROM notes
 WHERE tags @> $1::text []
+    AND deleted_at IS NULL
 ORDER BY created_at DESC;
 -- name: GetTagsWithCounts :many
 SELECT *
@@ -96,14 +144,475 @@ values ($1, $2, $3, $4, $5, $6, $7, $8)
 returning *;
 -- name: RandomNote :one
 SELECT *
-FROM notes OFFSET floor(
+FROM notes
+WHERE deleted_at IS NULL OFFSET floor(
         random() * (
             select count(*)
             from notes
+            where deleted_at is null
         )
     )
 limit 1;
 -- name: ArchiveNote :exec
 update notes
 set archive = TRUE
-where id = $1;
\ No newline at end of file
+where id = $1;
+-- name: UnarchiveNote :exec
+update notes
+set archive = FALSE
+where id = $1;
+-- name: FavoriteNote :exec
+update notes
+set favorite = TRUE
+where id = $1;
+-- name: UnfavoriteNote :exec
+update notes
+set favorite = FALSE
+where id = $1;
+-- name: CreateNoteRevision :one
+insert into note_revisions (id, note_id, title, note, tags, created_at)
+select @id::text,
+    notes.id,
+    notes.title,
+    notes.note,
+    notes.tags,
+    notes.modified_at
+from notes
+where notes.id = @note_id::text
+returning *;
+-- name: ListNoteRevisions :many
+select *
+from note_revisions
+where note_id = $1
+order by created_at desc;
+-- name: GetNoteRevision :one
+select *
+from note_revisions
+where id = $1
+    and note_id = $2
+limit 1;
+-- name: DeleteNoteLinks :exec
+delete from note_links
+where source_id = $1;
+-- name: CreateNoteLinks :exec
+insert into note_links (source_id, target)
+select @source_id::text,
+    unnest(@targets::text []) on conflict do nothing;
+-- name: ListBacklinks :many
+select distinct notes.*
+from notes
+    join note_links on note_links.source_id = notes.id
+where (
+        note_links.target = @id::text
+        or lower(note_links.target) = lower(@title::text)
+    )
+    and notes.id <> @id::text
+    and notes.deleted_at is null
+order by notes.created_at desc;
+-- name: ResolveWikiLink :one
+select id,
+    title
+from notes
+where (
+        id = @target::text
+        or lower(title) = lower(@target::text)
+    )
+    and deleted_at is null
+order by id = @target::text desc,
+    created_at
+limit 1;
+-- name: ResolveWikiLinks :many
+-- Resolve the wiki link targets to notes like ResolveWikiLink, with one row for each
+-- target that resolves to a note
+select distinct on (targets.target) targets.target,
+    notes.id,
+    notes.title
+from unnest(@targets::text []) as targets(target)
+    join notes on (
+        notes.id = targets.target
+        or lower(notes.title) = lower(targets.target)
+    )
+    and notes.deleted_at is null
+order by targets.target,
+    notes.id = targets.target desc,
+    notes.created_at;
+-- name: CreateAttachment :one
+insert into attachments (id, note_id, filename, content_type, size)
+values ($1, $2, $3, $4, $5)
+returning *;
+-- name: GetAttachment :one
+-- Get an attachment of a note that isn't in the trash
+select attachments.*
+from attachments
+    join notes on notes.id = attachments.note_id
+where attachments.id = $1
+    and notes.deleted_at is null
+limit 1;
+-- name: ListNoteAttachments :many
+select *
+from attachments
+where note_id = $1
+order by created_at;
+-- name: ListTrashedAttachments :many
+select attachments.id
+from attachments
+    join notes on notes.id = attachments.note_id
+where notes.deleted_at < @before::timestamptz;
+-- name: DeleteAttachment :exec
+delete from attachments
+where id = $1;
+-- name: SaveAttachmentData :exec
+insert into attachment_data (attachment_id, data)
+values ($1, $2) on conflict (attachment_id) do
+update
+set data = excluded.data;
+-- name: GetAttachmentData :one
+select data
+from attachment_data
+where attachment_id = $1;
+-- name: DeleteAttachmentData :exec
+delete from attachment_data
+where attachment_id = $1;
+-- name: ListNotebooks :many
+select notebooks.*,
+    count(notes.id) as note_count
+from notebooks
+    left join notes on notes.notebook_id = notebooks.id
+    and notes.deleted_at is null
+group by notebooks.id
+order by lower(notebooks.name);
+-- name: GetNotebook :one
+select *
+from notebooks
+where id = $1
+limit 1;
+-- name: CreateNotebook :one
+insert into notebooks (id, parent_id, name)
+values ($1, $2, $3)
+returning *;
+-- name: UpdateNotebook :one
+update notebooks
+set name = $2,
+    parent_id = $3
+where id = $1
+returning *;
+-- name: MoveNotebookNotes :exec
+update notes
+set notebook_id = sqlc.narg(parent_id)
+where notebook_id = @id::text;
+-- name: MoveNotebookChildren :exec
+update notebooks
+set parent_id = sqlc.narg(parent_id)
+where parent_id = @id::text;
+-- name: DeleteNotebook :execrows
+delete from notebooks
+where id = $1;
+-- name: ListTemplateNotes :many
+select *
+from notes
+where is_template = TRUE
+    and deleted_at is null
+order by lower(title);
+-- name: GetDailyNote :one
+select *
+from notes
+where daily_date = @daily_date::date
+    and deleted_at is null
+limit 1;
+-- name: CreateDailyNote :one
+insert into notes (
+        id,
+        title,
+        note,
+        archive,
+        favorite,
+        created_at,
+        modified_at,
+        tags,
+        daily_date
+    )
+values (
+        $1,
+        $2,
+        $3,
+        FALSE,
+        FALSE,
+        NOW(),
+        NOW(),
+        $4,
+        @daily_date::date
+    ) on conflict (daily_date)
+where daily_date is not null
+    and deleted_at is null do nothing
+returning *;
+-- name: ClaimDueReminders :many
+update notes
+set reminder_sent_at = NOW()
+where remind_at <= @now::timestamptz
+    and reminder_sent_at is null
+    and (
+        reminder_retry_at is null
+        or reminder_retry_at <= @now::timestamptz
+    )
+    and deleted_at is null
+returning *;
+-- name: FailReminder :exec
+-- Record a failed attempt to send a reminder. The reminder is sent again at retry_at, or
+-- stays claimed and is never sent when retry_at is null.
+update notes
+set reminder_attempts = reminder_attempts + 1,
+    reminder_retry_at = sqlc.narg(retry_at)::timestamptz,
+    reminder_sent_at = CASE
+        WHEN sqlc.narg(retry_at)::timestamptz IS NULL THEN reminder_sent_at
+        ELSE NULL
+    END
+where id = @id::text;
+-- name: SnoozeReminder :execrows
+update notes
+set remind_at = @remind_at::timestamptz,
+    reminder_sent_at = NULL,
+    reminder_attempts = 0,
+    reminder_retry_at = NULL
+where id = @id::text
+    and deleted_at is null;
+-- name: UpdateNoteText :one
+update notes
+set note = $2,
+    modified_at = NOW()
+where id = $1
+    and deleted_at is null
+returning *;
+-- name: GetNoteDraft :one
+select *
+from note_drafts
+where id = $1
+    and note_id is not distinct from $2
+limit 1;
+-- name: GetLatestNoteDraft :one
+select *
+from note_drafts
+where note_id is not distinct from $1
+order by modified_at desc
+limit 1;
+-- name: SaveNoteDraft :exec
+insert into note_drafts (id, note_id, title, note, tags, modified_at)
+values ($1, $2, $3, $4, $5, NOW()) on conflict (id) do
+update
+set title = excluded.title,
+    note = excluded.note,
+    tags = excluded.tags,
+    modified_at = excluded.modified_at
+where note_drafts.note_id is not distinct from excluded.note_id;
+-- name: DeleteNoteDraft :exec
+delete from note_drafts
+where id = $1
+    and note_id is not distinct from $2;
+-- name: ClaimDataMigration :execrows
+insert into data_migrations (name)
+values ($1) on conflict (name) do nothing;
+-- name: ListTags :many
+select *
+from tags
+order by name;
+-- name: ListPinnedTags :many
+select *
+from tags
+where pinned = TRUE
+order by name;
+-- name: GetTag :one
+select *
+from tags
+where name = $1;
+-- name: SaveTag :one
+insert into tags (name, description, color, pinned)
+values ($1, $2, $3, $4) on conflict (name) do
+update
+set description = excluded.description,
+    color = excluded.color,
+    pinned = excluded.pinned,
+    modified_at = NOW()
+returning *;
+-- name: RenameTag :exec
+update tags
+set name = @to_name::text,
+    modified_at = NOW()
+where name = @from_name::text
+    and not exists (
+        select 1
+        from tags
+        where name = @to_name::text
+    );
+-- name: DeleteTag :exec
+delete from tags
+where name = $1;
+-- name: ListTagRules :many
+select *
+from tag_rules
+order by created_at,
+    id;
+-- name: CreateTagRule :one
+insert into tag_rules (id, kind, value, tag)
+values ($1, $2, $3, $4)
+returning *;
+-- name: DeleteTagRule :execrows
+delete from tag_rules
+where id = $1;
+-- name: UpdateSearchLanguage :execrows
+update notes
+set search_language = @language::regconfig
+where search_language <> @language::regconfig;
+-- name: ListSavedSearches :many
+select *
+from saved_searches
+order by name,
+    id;
+-- name: ListPinnedSavedSearches :many
+select *
+from saved_searches
+where pinned = TRUE
+order by name,
+    id;
+-- name: CreateSavedSearch :one
+insert into saved_searches (id, name, query, pinned)
+values ($1, $2, $3, $4)
+returning *;
+-- name: UpdateSavedSearch :one
+update saved_searches
+set name = $2,
+    pinned = $3
+where id = $1
+returning *;
+-- name: DeleteSavedSearch :execrows
+delete from saved_searches
+where id = $1;
+-- name: SetSimilarityThreshold :exec
+-- Set the pg_trgm similarity threshold for the % operator until the end of the transaction
+select set_config(
+        'pg_trgm.similarity_threshold',
+        @threshold::real::text,
+        true
+    );
+-- name: ListRelatedNotes :many
+-- List the notes most like a note by the trigram similarity of their titles and text, plus
+-- a bonus for each tag they share. Only up to max_candidates notes each with similar titles,
+-- similar text and shared tags are scored. The similar notes are found with the trigram
+-- indexes and the % operator, so call SetSimilarityThreshold in the same transaction first.
+with note as (
+    select id,
+        title,
+        title || ' ' || note as body,
+        tags
+    from notes
+    where id = @id
+),
+candidates as (
+    (
+        select notes.id
+        from notes,
+            note
+        where notes.title % note.title
+            and notes.id <> note.id
+            and notes.deleted_at is null
+        order by similarity(notes.title, note.title) desc
+        limit @max_candidates
+    )
+    union
+    (
+        select notes.id
+        from notes,
+            note
+        where (notes.title || ' ' || notes.note) % note.body
+            and notes.id <> note.id
+            and notes.deleted_at is null
+        order by similarity(notes.title || ' ' || notes.note, note.body) desc
+        limit @max_candidates
+    )
+    union
+    (
+        select notes.id
+        from notes,
+            note
+        where notes.tags && note.tags
+            and notes.id <> note.id
+            and notes.deleted_at is null
+        order by notes.modified_at desc
+        limit @max_candidates
+    )
+),
+related as (
+    select candidates.id,
+        (
+            similarity(notes.title, note.title) + similarity(notes.title || ' ' || notes.note, note.body) + 0.1 * cardinality(
+                array(
+                    select unnest(notes.tags)
+                    intersect
+                    select unnest(note.tags)
+                )
+            )
+        )::real as score
+    from candidates
+        join notes on notes.id = candidates.id,
+        note
+)
+select sqlc.embed(notes),
+    related.score
+from notes
+    join related on related.id = notes.id
+where notes.id <> @id
+    and notes.deleted_at is null
+    and related.score >= @threshold::real
+order by related.score desc,
+    notes.id
+limit @max_results;
+-- name: ListDuplicateNotes :many
+-- List the pairs of notes whose titles and text are nearly the same, the most similar pairs
+-- first. Each note is paired with at most max_per_note later notes. The pairs are found
+-- with notes_text_trgm_idx and the % operator, so call SetSimilarityThreshold in the
+-- same transaction first.
+select a.id,
+    a.title,
+    a.created_at,
+    b.id as other_id,
+    b.title as other_title,
+    b.created_at as other_created_at,
+    b.similarity
+from notes as a
+    cross join lateral (
+        select n.id,
+            n.title,
+            n.created_at,
+            similarity(a.title || ' ' || a.note, n.title || ' ' || n.note)::real as similarity
+        from notes as n
+        where (n.title || ' ' || n.note) % (a.title || ' ' || a.note)
+            and n.id > a.id
+            and n.deleted_at is null
+            and not n.is_template
+        order by similarity desc,
+            n.id
+        limit @max_per_note
+    ) as b
+where a.deleted_at is null
+    and not a.is_template
+order by b.similarity desc,
+    a.id,
+    b.id
+limit @max_results;
+-- name: MoveNoteAttachments :exec
+update attachments
+set note_id = @to_id
+where note_id = @from_id;
+-- name: GetNoteRedirect :one
+select note_id
+from note_redirects
+where old_id = $1;
+-- name: CreateNoteRedirect :exec
+insert into note_redirects (old_id, note_id)
+values ($1, $2) on conflict (old_id) do
+update
+set note_id = excluded.note_id,
+    created_at = NOW();
+-- name: MoveNoteRedirects :exec
+-- Point the redirects to a note at another note, for when the note is merged into it
+update note_redirects
+set note_id = @to_id
+where note_id = @from_id;
\ No newline at end of file
@@ -21,7 +21,106 @@ func (q *Queries) ArchiveNote(ctx context.Context, id string) error {
 	return err
 }
 
-const createNote = `-- name: CreateNote :one
+const claimDataMigration = `-- name: ClaimDataMigration :execrows
+insert into data_migrations (name)
+values ($1) on conflict (name) do nothing
+`
+
+func (q *Queries) ClaimDataMigration(ctx context.Context, name string) (int64, error) {
+	result, err := q.db.Exec(ctx, claimDataMigration, name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const claimDueReminders = `-- name: ClaimDueReminders :many
+update notes
+set reminder_sent_at = NOW()
+where remind_at <= $1::timestamptz
+    and reminder_sent_at is null
+    and (
+        reminder_retry_at is null
+        or reminder_retry_at <= $1::timestamptz
+    )
+    and deleted_at is null
+returning id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
+`
+
+func (q *Queries) ClaimDueReminders(ctx context.Context, now time.Time) ([]Note, error) {
+	rows, err := q.db.Query(ctx, claimDueReminders, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Note
+	for rows.Next() {
+		var i Note
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Note,
+			&i.Archive,
+			&i.Favorite,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createAttachment = `-- name: CreateAttachment :one
+insert into attachments (id, note_id, filename, content_type, size)
+values ($1, $2, $3, $4, $5)
+returning id, note_id, filename, content_type, size, created_at
+`
+
+type CreateAttachmentParams struct {
+	ID          string
+	NoteID      string
+	Filename    string
+	ContentType string
+	Size        int64
+}
+
+func (q *Queries) CreateAttachment(ctx context.Context, arg CreateAttachmentParams) (Attachment, error) {
+	row := q.db.QueryRow(ctx, createAttachment,
+		arg.ID,
+		arg.NoteID,
+		arg.Filename,
+		arg.ContentType,
+		arg.Size,
+	)
+	var i Attachment
+	err := row.Scan(
+		&i.ID,
+		&i.NoteID,
+		&i.Filename,
+		&i.ContentType,
+		&i.Size,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createDailyNote = `-- name: CreateDailyNote :one
 insert into notes (
         id,
         title,
@@ -30,20 +129,96 @@ insert into notes (
         favorite,
         created_at,
         modified_at,
-        tags
+        tags,
+        daily_date
     )
-values ($1, $2, $3, $4, $5, $6, NOW(), $7)
-returning id, title, note, archive, favorite, created_at, modified_at, tags
+values (
+        $1,
+        $2,
+        $3,
+        FALSE,
+        FALSE,
+        NOW(),
+        NOW(),
+        $4,
+        $5::date
+    ) on conflict (daily_date)
+where daily_date is not null
+    and deleted_at is null do nothing
+returning id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
 `
 
-type CreateNoteParams struct {
+type CreateDailyNoteParams struct {
 	ID        string
 	Title     string
 	Note      string
-	Archive   bool
-	Favorite  bool
-	CreatedAt time.Time
 	Tags      []string
+	DailyDate time.Time
+}
+
+func (q *Queries) CreateDailyNote(ctx context.Context, arg CreateDailyNoteParams) (Note, error) {
+	row := q.db.QueryRow(ctx, createDailyNote,
+		arg.ID,
+		arg.Title,
+		arg.Note,
+		arg.Tags,
+		arg.DailyDate,
+	)
+	var i Note
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Note,
+		&i.Archive,
+		&i.Favorite,
+		&i.CreatedAt,
+		&i.ModifiedAt,
+		&i.Tags,
+		&i.DeletedAt,
+		&i.NotebookID,
+		&i.IsTemplate,
+		&i.DailyDate,
+		&i.RemindAt,
+		&i.ReminderSentAt,
+		&i.ExplicitTags,
+		&i.SearchLanguage,
+		&i.ReminderAttempts,
+		&i.ReminderRetryAt,
+	)
+	return i, err
+}
+
+const createNote = `-- name: CreateNote :one
+insert into notes (
+        id,
+        title,
+        note,
+        archive,
+        favorite,
+        created_at,
+        modified_at,
+        tags,
+        notebook_id,
+        is_template,
+        remind_at,
+        explicit_tags
+    )
+values ($1, $2, $3, $4, $5, $6, NOW(), $7, $8, $9, $10, $11)
+returning id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
+`
+
+type CreateNoteParams struct {
+	ID           string
+	Title        string
+	Note         string
+	Archive      bool
+	Favorite     bool
+	CreatedAt    time.Time
+	Tags         []string
+	NotebookID   *string
+	IsTemplate   bool
+	RemindAt     *time.Time
+	ExplicitTags []string
 }
 
 func (q *Queries) CreateNote(ctx context.Context, arg CreateNoteParams) (Note, error) {
@@ -55,6 +230,10 @@ func (q *Queries) CreateNote(ctx context.Context, arg CreateNoteParams) (Note, e
 		arg.Favorite,
 		arg.CreatedAt,
 		arg.Tags,
+		arg.NotebookID,
+		arg.IsTemplate,
+		arg.RemindAt,
+		arg.ExplicitTags,
 	)
 	var i Note
 	err := row.Scan(
@@ -66,10 +245,192 @@ func (q *Queries) CreateNote(ctx context.Context, arg CreateNoteParams) (Note, e
 		&i.CreatedAt,
 		&i.ModifiedAt,
 		&i.Tags,
+		&i.DeletedAt,
+		&i.NotebookID,
+		&i.IsTemplate,
+		&i.DailyDate,
+		&i.RemindAt,
+		&i.ReminderSentAt,
+		&i.ExplicitTags,
+		&i.SearchLanguage,
+		&i.ReminderAttempts,
+		&i.ReminderRetryAt,
+	)
+	return i, err
+}
+
+const createNoteLinks = `-- name: CreateNoteLinks :exec
+insert into note_links (source_id, target)
+select $1::text,
+    unnest($2::text []) on conflict do nothing
+`
+
+type CreateNoteLinksParams struct {
+	SourceID string
+	Targets  []string
+}
+
+func (q *Queries) CreateNoteLinks(ctx context.Context, arg CreateNoteLinksParams) error {
+	_, err := q.db.Exec(ctx, createNoteLinks, arg.SourceID, arg.Targets)
+	return err
+}
+
+const createNoteRedirect = `-- name: CreateNoteRedirect :exec
+insert into note_redirects (old_id, note_id)
+values ($1, $2) on conflict (old_id) do
+update
+set note_id = excluded.note_id,
+    created_at = NOW()
+`
+
+type CreateNoteRedirectParams struct {
+	OldID  string
+	NoteID string
+}
+
+func (q *Queries) CreateNoteRedirect(ctx context.Context, arg CreateNoteRedirectParams) error {
+	_, err := q.db.Exec(ctx, createNoteRedirect, arg.OldID, arg.NoteID)
+	return err
+}
+
+const createNoteRevision = `-- name: CreateNoteRevision :one
+insert into note_revisions (id, note_id, title, note, tags, created_at)
+select $1::text,
+    notes.id,
+    notes.title,
+    notes.note,
+    notes.tags,
+    notes.modified_at
+from notes
+where notes.id = $2::text
+returning id, note_id, title, note, tags, created_at
+`
+
+type CreateNoteRevisionParams struct {
+	ID     string
+	NoteID string
+}
+
+func (q *Queries) CreateNoteRevision(ctx context.Context, arg CreateNoteRevisionParams) (NoteRevision, error) {
+	row := q.db.QueryRow(ctx, createNoteRevision, arg.ID, arg.NoteID)
+	var i NoteRevision
+	err := row.Scan(
+		&i.ID,
+		&i.NoteID,
+		&i.Title,
+		&i.Note,
+		&i.Tags,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createNotebook = `-- name: CreateNotebook :one
+insert into notebooks (id, parent_id, name)
+values ($1, $2, $3)
+returning id, parent_id, name, created_at
+`
+
+type CreateNotebookParams struct {
+	ID       string
+	ParentID *string
+	Name     string
+}
+
+func (q *Queries) CreateNotebook(ctx context.Context, arg CreateNotebookParams) (Notebook, error) {
+	row := q.db.QueryRow(ctx, createNotebook, arg.ID, arg.ParentID, arg.Name)
+	var i Notebook
+	err := row.Scan(
+		&i.ID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
 	)
 	return i, err
 }
 
+const createSavedSearch = `-- name: CreateSavedSearch :one
+insert into saved_searches (id, name, query, pinned)
+values ($1, $2, $3, $4)
+returning id, name, query, pinned, created_at
+`
+
+type CreateSavedSearchParams struct {
+	ID     string
+	Name   string
+	Query  string
+	Pinned bool
+}
+
+func (q *Queries) CreateSavedSearch(ctx context.Context, arg CreateSavedSearchParams) (SavedSearch, error) {
+	row := q.db.QueryRow(ctx, createSavedSearch,
+		arg.ID,
+		arg.Name,
+		arg.Query,
+		arg.Pinned,
+	)
+	var i SavedSearch
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Query,
+		&i.Pinned,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createTagRule = `-- name: CreateTagRule :one
+insert into tag_rules (id, kind, value, tag)
+values ($1, $2, $3, $4)
+returning id, kind, value, tag, created_at
+`
+
+type CreateTagRuleParams struct {
+	ID    string
+	Kind  string
+	Value string
+	Tag   string
+}
+
+func (q *Queries) CreateTagRule(ctx context.Context, arg CreateTagRuleParams) (TagRule, error) {
+	row := q.db.QueryRow(ctx, createTagRule,
+		arg.ID,
+		arg.Kind,
+		arg.Value,
+		arg.Tag,
+	)
+	var i TagRule
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Value,
+		&i.Tag,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteAttachment = `-- name: DeleteAttachment :exec
+delete from attachments
+where id = $1
+`
+
+func (q *Queries) DeleteAttachment(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, deleteAttachment, id)
+	return err
+}
+
+const deleteAttachmentData = `-- name: DeleteAttachmentData :exec
+delete from attachment_data
+where attachment_id = $1
+`
+
+func (q *Queries) DeleteAttachmentData(ctx context.Context, attachmentID string) error {
+	_, err := q.db.Exec(ctx, deleteAttachmentData, attachmentID)
+	return err
+}
+
 const deleteNote = `-- name: DeleteNote :exec
 delete from notes
 where id = $1
@@ -80,10 +441,134 @@ func (q *Queries) DeleteNote(ctx context.Context, id string) error {
 	return err
 }
 
+const deleteNoteDraft = `-- name: DeleteNoteDraft :exec
+delete from note_drafts
+where id = $1
+    and note_id is not distinct from $2
+`
+
+type DeleteNoteDraftParams struct {
+	ID     string
+	NoteID *string
+}
+
+func (q *Queries) DeleteNoteDraft(ctx context.Context, arg DeleteNoteDraftParams) error {
+	_, err := q.db.Exec(ctx, deleteNoteDraft, arg.ID, arg.NoteID)
+	return err
+}
+
+const deleteNoteLinks = `-- name: DeleteNoteLinks :exec
+delete from note_links
+where source_id = $1
+`
+
+func (q *Queries) DeleteNoteLinks(ctx context.Context, sourceID string) error {
+	_, err := q.db.Exec(ctx, deleteNoteLinks, sourceID)
+	return err
+}
+
+const deleteNotebook = `-- name: DeleteNotebook :execrows
+delete from notebooks
+where id = $1
+`
+
+func (q *Queries) DeleteNotebook(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteNotebook, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteSavedSearch = `-- name: DeleteSavedSearch :execrows
+delete from saved_searches
+where id = $1
+`
+
+func (q *Queries) DeleteSavedSearch(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteSavedSearch, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteTag = `-- name: DeleteTag :exec
+delete from tags
+where name = $1
+`
+
+func (q *Queries) DeleteTag(ctx context.Context, name string) error {
+	_, err := q.db.Exec(ctx, deleteTag, name)
+	return err
+}
+
+const deleteTagRule = `-- name: DeleteTagRule :execrows
+delete from tag_rules
+where id = $1
+`
+
+func (q *Queries) DeleteTagRule(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTagRule, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteTrashedNote = `-- name: DeleteTrashedNote :execrows
+delete from notes
+where id = $1
+    and deleted_at is not null
+`
+
+func (q *Queries) DeleteTrashedNote(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTrashedNote, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const failReminder = `-- name: FailReminder :exec
+update notes
+set reminder_attempts = reminder_attempts + 1,
+    reminder_retry_at = $1::timestamptz,
+    reminder_sent_at = CASE
+        WHEN $1::timestamptz IS NULL THEN reminder_sent_at
+        ELSE NULL
+    END
+where id = $2::text
+`
+
+type FailReminderParams struct {
+	RetryAt *time.Time
+	ID      string
+}
+
+// Record a failed attempt to send a reminder. The reminder is sent again at retry_at, or
+// stays claimed and is never sent when retry_at is null.
+func (q *Queries) FailReminder(ctx context.Context, arg FailReminderParams) error {
+	_, err := q.db.Exec(ctx, failReminder, arg.RetryAt, arg.ID)
+	return err
+}
+
+const favoriteNote = `-- name: FavoriteNote :exec
+update notes
+set favorite = TRUE
+where id = $1
+`
+
+func (q *Queries) FavoriteNote(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, favoriteNote, id)
+	return err
+}
+
 const findNotesWithTags = `-- name: FindNotesWithTags :many
-SELECT id, title, note, archive, favorite, created_at, modified_at, tags
+SELECT id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
 FROM notes
 WHERE tags @> $1::text []
+    AND deleted_at IS NULL
 ORDER BY created_at DESC
 `
 
@@ -105,6 +590,16 @@ func (q *Queries) FindNotesWithTags(ctx context.Context, dollar_1 []string) ([]N
 			&i.CreatedAt,
 			&i.ModifiedAt,
 			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
 		); err != nil {
 			return nil, err
 		}
@@ -116,15 +611,53 @@ func (q *Queries) FindNotesWithTags(ctx context.Context, dollar_1 []string) ([]N
 	return items, nil
 }
 
-const getNote = `-- name: GetNote :one
-select id, title, note, archive, favorite, created_at, modified_at, tags
+const getAttachment = `-- name: GetAttachment :one
+select attachments.id, attachments.note_id, attachments.filename, attachments.content_type, attachments.size, attachments.created_at
+from attachments
+    join notes on notes.id = attachments.note_id
+where attachments.id = $1
+    and notes.deleted_at is null
+limit 1
+`
+
+// Get an attachment of a note that isn't in the trash
+func (q *Queries) GetAttachment(ctx context.Context, id string) (Attachment, error) {
+	row := q.db.QueryRow(ctx, getAttachment, id)
+	var i Attachment
+	err := row.Scan(
+		&i.ID,
+		&i.NoteID,
+		&i.Filename,
+		&i.ContentType,
+		&i.Size,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAttachmentData = `-- name: GetAttachmentData :one
+select data
+from attachment_data
+where attachment_id = $1
+`
+
+func (q *Queries) GetAttachmentData(ctx context.Context, attachmentID string) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getAttachmentData, attachmentID)
+	var data []byte
+	err := row.Scan(&data)
+	return data, err
+}
+
+const getDailyNote = `-- name: GetDailyNote :one
+select id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
 from notes
-where id = $1
+where daily_date = $1::date
+    and deleted_at is null
 limit 1
 `
 
-func (q *Queries) GetNote(ctx context.Context, id string) (Note, error) {
-	row := q.db.QueryRow(ctx, getNote, id)
+func (q *Queries) GetDailyNote(ctx context.Context, dailyDate time.Time) (Note, error) {
+	row := q.db.QueryRow(ctx, getDailyNote, dailyDate)
 	var i Note
 	err := row.Scan(
 		&i.ID,
@@ -135,20 +668,191 @@ func (q *Queries) GetNote(ctx context.Context, id string) (Note, error) {
 		&i.CreatedAt,
 		&i.ModifiedAt,
 		&i.Tags,
+		&i.DeletedAt,
+		&i.NotebookID,
+		&i.IsTemplate,
+		&i.DailyDate,
+		&i.RemindAt,
+		&i.ReminderSentAt,
+		&i.ExplicitTags,
+		&i.SearchLanguage,
+		&i.ReminderAttempts,
+		&i.ReminderRetryAt,
 	)
 	return i, err
 }
 
-const getTagsWithCounts = `-- name: GetTagsWithCounts :many
-SELECT tag_name, note_count
-FROM tag_summary
-ORDER BY tag_name
+const getLatestNoteDraft = `-- name: GetLatestNoteDraft :one
+select id, note_id, title, note, modified_at, tags
+from note_drafts
+where note_id is not distinct from $1
+order by modified_at desc
+limit 1
 `
 
-func (q *Queries) GetTagsWithCounts(ctx context.Context) ([]TagSummary, error) {
-	rows, err := q.db.Query(ctx, getTagsWithCounts)
-	if err != nil {
-		return nil, err
+func (q *Queries) GetLatestNoteDraft(ctx context.Context, noteID *string) (NoteDraft, error) {
+	row := q.db.QueryRow(ctx, getLatestNoteDraft, noteID)
+	var i NoteDraft
+	err := row.Scan(
+		&i.ID,
+		&i.NoteID,
+		&i.Title,
+		&i.Note,
+		&i.ModifiedAt,
+		&i.Tags,
+	)
+	return i, err
+}
+
+const getNote = `-- name: GetNote :one
+select id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
+from notes
+where id = $1
+    and deleted_at is null
+limit 1
+`
+
+func (q *Queries) GetNote(ctx context.Context, id string) (Note, error) {
+	row := q.db.QueryRow(ctx, getNote, id)
+	var i Note
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Note,
+		&i.Archive,
+		&i.Favorite,
+		&i.CreatedAt,
+		&i.ModifiedAt,
+		&i.Tags,
+		&i.DeletedAt,
+		&i.NotebookID,
+		&i.IsTemplate,
+		&i.DailyDate,
+		&i.RemindAt,
+		&i.ReminderSentAt,
+		&i.ExplicitTags,
+		&i.SearchLanguage,
+		&i.ReminderAttempts,
+		&i.ReminderRetryAt,
+	)
+	return i, err
+}
+
+const getNoteDraft = `-- name: GetNoteDraft :one
+select id, note_id, title, note, modified_at, tags
+from note_drafts
+where id = $1
+    and note_id is not distinct from $2
+limit 1
+`
+
+type GetNoteDraftParams struct {
+	ID     string
+	NoteID *string
+}
+
+func (q *Queries) GetNoteDraft(ctx context.Context, arg GetNoteDraftParams) (NoteDraft, error) {
+	row := q.db.QueryRow(ctx, getNoteDraft, arg.ID, arg.NoteID)
+	var i NoteDraft
+	err := row.Scan(
+		&i.ID,
+		&i.NoteID,
+		&i.Title,
+		&i.Note,
+		&i.ModifiedAt,
+		&i.Tags,
+	)
+	return i, err
+}
+
+const getNoteRedirect = `-- name: GetNoteRedirect :one
+select note_id
+from note_redirects
+where old_id = $1
+`
+
+func (q *Queries) GetNoteRedirect(ctx context.Context, oldID string) (string, error) {
+	row := q.db.QueryRow(ctx, getNoteRedirect, oldID)
+	var note_id string
+	err := row.Scan(&note_id)
+	return note_id, err
+}
+
+const getNoteRevision = `-- name: GetNoteRevision :one
+select id, note_id, title, note, tags, created_at
+from note_revisions
+where id = $1
+    and note_id = $2
+limit 1
+`
+
+type GetNoteRevisionParams struct {
+	ID     string
+	NoteID string
+}
+
+func (q *Queries) GetNoteRevision(ctx context.Context, arg GetNoteRevisionParams) (NoteRevision, error) {
+	row := q.db.QueryRow(ctx, getNoteRevision, arg.ID, arg.NoteID)
+	var i NoteRevision
+	err := row.Scan(
+		&i.ID,
+		&i.NoteID,
+		&i.Title,
+		&i.Note,
+		&i.Tags,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getNotebook = `-- name: GetNotebook :one
+select id, parent_id, name, created_at
+from notebooks
+where id = $1
+limit 1
+`
+
+func (q *Queries) GetNotebook(ctx context.Context, id string) (Notebook, error) {
+	row := q.db.QueryRow(ctx, getNotebook, id)
+	var i Notebook
+	err := row.Scan(
+		&i.ID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getTag = `-- name: GetTag :one
+select name, description, color, pinned, modified_at
+from tags
+where name = $1
+`
+
+func (q *Queries) GetTag(ctx context.Context, name string) (Tag, error) {
+	row := q.db.QueryRow(ctx, getTag, name)
+	var i Tag
+	err := row.Scan(
+		&i.Name,
+		&i.Description,
+		&i.Color,
+		&i.Pinned,
+		&i.ModifiedAt,
+	)
+	return i, err
+}
+
+const getTagsWithCounts = `-- name: GetTagsWithCounts :many
+SELECT tag_name, note_count
+FROM tag_summary
+ORDER BY tag_name
+`
+
+func (q *Queries) GetTagsWithCounts(ctx context.Context) ([]TagSummary, error) {
+	rows, err := q.db.Query(ctx, getTagsWithCounts)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 	var items []TagSummary
@@ -177,7 +881,7 @@ insert into notes (
         tags
     )
 values ($1, $2, $3, $4, $5, $6, $7, $8)
-returning id, title, note, archive, favorite, created_at, modified_at, tags
+returning id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
 `
 
 type ImportNoteParams struct {
@@ -212,12 +916,68 @@ func (q *Queries) ImportNote(ctx context.Context, arg ImportNoteParams) (Note, e
 		&i.CreatedAt,
 		&i.ModifiedAt,
 		&i.Tags,
+		&i.DeletedAt,
+		&i.NotebookID,
+		&i.IsTemplate,
+		&i.DailyDate,
+		&i.RemindAt,
+		&i.ReminderSentAt,
+		&i.ExplicitTags,
+		&i.SearchLanguage,
+		&i.ReminderAttempts,
+		&i.ReminderRetryAt,
 	)
 	return i, err
 }
 
+const listActiveNotes = `-- name: ListActiveNotes :many
+select id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
+from notes
+where deleted_at is null
+order by created_at desc
+`
+
+func (q *Queries) ListActiveNotes(ctx context.Context) ([]Note, error) {
+	rows, err := q.db.Query(ctx, listActiveNotes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Note
+	for rows.Next() {
+		var i Note
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Note,
+			&i.Archive,
+			&i.Favorite,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listAllNotes = `-- name: ListAllNotes :many
-select id, title, note, archive, favorite, created_at, modified_at, tags
+select id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
 from notes
 `
 
@@ -238,7 +998,701 @@ func (q *Queries) ListAllNotes(ctx context.Context) ([]Note, error) {
 			&i.Favorite,
 			&i.CreatedAt,
 			&i.ModifiedAt,
-			&i.Tags,
+			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listArchivedNotes = `-- name: ListArchivedNotes :many
+select id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
+from notes
+where archive = TRUE
+    and deleted_at is null
+order by created_at desc
+`
+
+func (q *Queries) ListArchivedNotes(ctx context.Context) ([]Note, error) {
+	rows, err := q.db.Query(ctx, listArchivedNotes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Note
+	for rows.Next() {
+		var i Note
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Note,
+			&i.Archive,
+			&i.Favorite,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBacklinks = `-- name: ListBacklinks :many
+select distinct notes.id, notes.title, notes.note, notes.archive, notes.favorite, notes.created_at, notes.modified_at, notes.tags, notes.deleted_at, notes.notebook_id, notes.is_template, notes.daily_date, notes.remind_at, notes.reminder_sent_at, notes.explicit_tags, notes.search_language, notes.reminder_attempts, notes.reminder_retry_at
+from notes
+    join note_links on note_links.source_id = notes.id
+where (
+        note_links.target = $1::text
+        or lower(note_links.target) = lower($2::text)
+    )
+    and notes.id <> $1::text
+    and notes.deleted_at is null
+order by notes.created_at desc
+`
+
+type ListBacklinksParams struct {
+	ID    string
+	Title string
+}
+
+func (q *Queries) ListBacklinks(ctx context.Context, arg ListBacklinksParams) ([]Note, error) {
+	rows, err := q.db.Query(ctx, listBacklinks, arg.ID, arg.Title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Note
+	for rows.Next() {
+		var i Note
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Note,
+			&i.Archive,
+			&i.Favorite,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDuplicateNotes = `-- name: ListDuplicateNotes :many
+select a.id,
+    a.title,
+    a.created_at,
+    b.id as other_id,
+    b.title as other_title,
+    b.created_at as other_created_at,
+    b.similarity
+from notes as a
+    cross join lateral (
+        select n.id,
+            n.title,
+            n.created_at,
+            similarity(a.title || ' ' || a.note, n.title || ' ' || n.note)::real as similarity
+        from notes as n
+        where (n.title || ' ' || n.note) % (a.title || ' ' || a.note)
+            and n.id > a.id
+            and n.deleted_at is null
+            and not n.is_template
+        order by similarity desc,
+            n.id
+        limit $1
+    ) as b
+where a.deleted_at is null
+    and not a.is_template
+order by b.similarity desc,
+    a.id,
+    b.id
+limit $2
+`
+
+type ListDuplicateNotesParams struct {
+	MaxPerNote int32
+	MaxResults int32
+}
+
+type ListDuplicateNotesRow struct {
+	ID             string
+	Title          string
+	CreatedAt      time.Time
+	OtherID        string
+	OtherTitle     string
+	OtherCreatedAt time.Time
+	Similarity     float32
+}
+
+// List the pairs of notes whose titles and text are nearly the same, the most similar pairs
+// first. Each note is paired with at most max_per_note later notes. The pairs are found
+// with notes_text_trgm_idx and the % operator, so call SetSimilarityThreshold in the
+// same transaction first.
+func (q *Queries) ListDuplicateNotes(ctx context.Context, arg ListDuplicateNotesParams) ([]ListDuplicateNotesRow, error) {
+	rows, err := q.db.Query(ctx, listDuplicateNotes, arg.MaxPerNote, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDuplicateNotesRow
+	for rows.Next() {
+		var i ListDuplicateNotesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.CreatedAt,
+			&i.OtherID,
+			&i.OtherTitle,
+			&i.OtherCreatedAt,
+			&i.Similarity,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFavoriteNotes = `-- name: ListFavoriteNotes :many
+select id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
+from notes
+where favorite = TRUE
+    and deleted_at is null
+order by modified_at desc
+`
+
+func (q *Queries) ListFavoriteNotes(ctx context.Context) ([]Note, error) {
+	rows, err := q.db.Query(ctx, listFavoriteNotes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Note
+	for rows.Next() {
+		var i Note
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Note,
+			&i.Archive,
+			&i.Favorite,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNoteAttachments = `-- name: ListNoteAttachments :many
+select id, note_id, filename, content_type, size, created_at
+from attachments
+where note_id = $1
+order by created_at
+`
+
+func (q *Queries) ListNoteAttachments(ctx context.Context, noteID string) ([]Attachment, error) {
+	rows, err := q.db.Query(ctx, listNoteAttachments, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Attachment
+	for rows.Next() {
+		var i Attachment
+		if err := rows.Scan(
+			&i.ID,
+			&i.NoteID,
+			&i.Filename,
+			&i.ContentType,
+			&i.Size,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNoteRevisions = `-- name: ListNoteRevisions :many
+select id, note_id, title, note, tags, created_at
+from note_revisions
+where note_id = $1
+order by created_at desc
+`
+
+func (q *Queries) ListNoteRevisions(ctx context.Context, noteID string) ([]NoteRevision, error) {
+	rows, err := q.db.Query(ctx, listNoteRevisions, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NoteRevision
+	for rows.Next() {
+		var i NoteRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.NoteID,
+			&i.Title,
+			&i.Note,
+			&i.Tags,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNotebooks = `-- name: ListNotebooks :many
+select notebooks.id, notebooks.parent_id, notebooks.name, notebooks.created_at,
+    count(notes.id) as note_count
+from notebooks
+    left join notes on notes.notebook_id = notebooks.id
+    and notes.deleted_at is null
+group by notebooks.id
+order by lower(notebooks.name)
+`
+
+type ListNotebooksRow struct {
+	ID        string
+	ParentID  *string
+	Name      string
+	CreatedAt time.Time
+	NoteCount int64
+}
+
+func (q *Queries) ListNotebooks(ctx context.Context) ([]ListNotebooksRow, error) {
+	rows, err := q.db.Query(ctx, listNotebooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListNotebooksRow
+	for rows.Next() {
+		var i ListNotebooksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ParentID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.NoteCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNotes = `-- name: ListNotes :many
+select id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
+from notes
+where archive != TRUE
+    and deleted_at is null
+order by created_at desc
+`
+
+func (q *Queries) ListNotes(ctx context.Context) ([]Note, error) {
+	rows, err := q.db.Query(ctx, listNotes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Note
+	for rows.Next() {
+		var i Note
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Note,
+			&i.Archive,
+			&i.Favorite,
+			&i.CreatedAt,
+			&i.ModifiedAt,
+			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPinnedSavedSearches = `-- name: ListPinnedSavedSearches :many
+select id, name, query, pinned, created_at
+from saved_searches
+where pinned = TRUE
+order by name,
+    id
+`
+
+func (q *Queries) ListPinnedSavedSearches(ctx context.Context) ([]SavedSearch, error) {
+	rows, err := q.db.Query(ctx, listPinnedSavedSearches)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SavedSearch
+	for rows.Next() {
+		var i SavedSearch
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Query,
+			&i.Pinned,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPinnedTags = `-- name: ListPinnedTags :many
+select name, description, color, pinned, modified_at
+from tags
+where pinned = TRUE
+order by name
+`
+
+func (q *Queries) ListPinnedTags(ctx context.Context) ([]Tag, error) {
+	rows, err := q.db.Query(ctx, listPinnedTags)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Tag
+	for rows.Next() {
+		var i Tag
+		if err := rows.Scan(
+			&i.Name,
+			&i.Description,
+			&i.Color,
+			&i.Pinned,
+			&i.ModifiedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRelatedNotes = `-- name: ListRelatedNotes :many
+with note as (
+    select id,
+        title,
+        title || ' ' || note as body,
+        tags
+    from notes
+    where id = $1
+),
+candidates as (
+    (
+        select notes.id
+        from notes,
+            note
+        where notes.title % note.title
+            and notes.id <> note.id
+            and notes.deleted_at is null
+        order by similarity(notes.title, note.title) desc
+        limit $2
+    )
+    union
+    (
+        select notes.id
+        from notes,
+            note
+        where (notes.title || ' ' || notes.note) % note.body
+            and notes.id <> note.id
+            and notes.deleted_at is null
+        order by similarity(notes.title || ' ' || notes.note, note.body) desc
+        limit $2
+    )
+    union
+    (
+        select notes.id
+        from notes,
+            note
+        where notes.tags && note.tags
+            and notes.id <> note.id
+            and notes.deleted_at is null
+        order by notes.modified_at desc
+        limit $2
+    )
+),
+related as (
+    select candidates.id,
+        (
+            similarity(notes.title, note.title) + similarity(notes.title || ' ' || notes.note, note.body) + 0.1 * cardinality(
+                array(
+                    select unnest(notes.tags)
+                    intersect
+                    select unnest(note.tags)
+                )
+            )
+        )::real as score
+    from candidates
+        join notes on notes.id = candidates.id,
+        note
+)
+select notes.id, notes.title, notes.note, notes.archive, notes.favorite, notes.created_at, notes.modified_at, notes.tags, notes.deleted_at, notes.notebook_id, notes.is_template, notes.daily_date, notes.remind_at, notes.reminder_sent_at, notes.explicit_tags, notes.search_language, notes.reminder_attempts, notes.reminder_retry_at,
+    related.score
+from notes
+    join related on related.id = notes.id
+where notes.id <> $1
+    and notes.deleted_at is null
+    and related.score >= $3::real
+order by related.score desc,
+    notes.id
+limit $4
+`
+
+type ListRelatedNotesParams struct {
+	ID            string
+	MaxCandidates int32
+	Threshold     float32
+	MaxResults    int32
+}
+
+type ListRelatedNotesRow struct {
+	Note  Note
+	Score float32
+}
+
+// List the notes most like a note by the trigram similarity of their titles and text, plus
+// a bonus for each tag they share. Only up to max_candidates notes each with similar titles,
+// similar text and shared tags are scored. The similar notes are found with the trigram
+// indexes and the % operator, so call SetSimilarityThreshold in the same transaction first.
+func (q *Queries) ListRelatedNotes(ctx context.Context, arg ListRelatedNotesParams) ([]ListRelatedNotesRow, error) {
+	rows, err := q.db.Query(ctx, listRelatedNotes,
+		arg.ID,
+		arg.MaxCandidates,
+		arg.Threshold,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRelatedNotesRow
+	for rows.Next() {
+		var i ListRelatedNotesRow
+		if err := rows.Scan(
+			&i.Note.ID,
+			&i.Note.Title,
+			&i.Note.Note,
+			&i.Note.Archive,
+			&i.Note.Favorite,
+			&i.Note.CreatedAt,
+			&i.Note.ModifiedAt,
+			&i.Note.Tags,
+			&i.Note.DeletedAt,
+			&i.Note.NotebookID,
+			&i.Note.IsTemplate,
+			&i.Note.DailyDate,
+			&i.Note.RemindAt,
+			&i.Note.ReminderSentAt,
+			&i.Note.ExplicitTags,
+			&i.Note.SearchLanguage,
+			&i.Note.ReminderAttempts,
+			&i.Note.ReminderRetryAt,
+			&i.Score,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSavedSearches = `-- name: ListSavedSearches :many
+select id, name, query, pinned, created_at
+from saved_searches
+order by name,
+    id
+`
+
+func (q *Queries) ListSavedSearches(ctx context.Context) ([]SavedSearch, error) {
+	rows, err := q.db.Query(ctx, listSavedSearches)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SavedSearch
+	for rows.Next() {
+		var i SavedSearch
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Query,
+			&i.Pinned,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagRules = `-- name: ListTagRules :many
+select id, kind, value, tag, created_at
+from tag_rules
+order by created_at,
+    id
+`
+
+func (q *Queries) ListTagRules(ctx context.Context) ([]TagRule, error) {
+	rows, err := q.db.Query(ctx, listTagRules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TagRule
+	for rows.Next() {
+		var i TagRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Value,
+			&i.Tag,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTags = `-- name: ListTags :many
+select name, description, color, pinned, modified_at
+from tags
+order by name
+`
+
+func (q *Queries) ListTags(ctx context.Context) ([]Tag, error) {
+	rows, err := q.db.Query(ctx, listTags)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Tag
+	for rows.Next() {
+		var i Tag
+		if err := rows.Scan(
+			&i.Name,
+			&i.Description,
+			&i.Color,
+			&i.Pinned,
+			&i.ModifiedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -250,15 +1704,16 @@ func (q *Queries) ListAllNotes(ctx context.Context) ([]Note, error) {
 	return items, nil
 }
 
-const listArchivedNotes = `-- name: ListArchivedNotes :many
-select id, title, note, archive, favorite, created_at, modified_at, tags
+const listTemplateNotes = `-- name: ListTemplateNotes :many
+select id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
 from notes
-where archive = TRUE
-order by created_at desc
+where is_template = TRUE
+    and deleted_at is null
+order by lower(title)
 `
 
-func (q *Queries) ListArchivedNotes(ctx context.Context) ([]Note, error) {
-	rows, err := q.db.Query(ctx, listArchivedNotes)
+func (q *Queries) ListTemplateNotes(ctx context.Context) ([]Note, error) {
+	rows, err := q.db.Query(ctx, listTemplateNotes)
 	if err != nil {
 		return nil, err
 	}
@@ -275,6 +1730,16 @@ func (q *Queries) ListArchivedNotes(ctx context.Context) ([]Note, error) {
 			&i.CreatedAt,
 			&i.ModifiedAt,
 			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
 		); err != nil {
 			return nil, err
 		}
@@ -286,35 +1751,26 @@ func (q *Queries) ListArchivedNotes(ctx context.Context) ([]Note, error) {
 	return items, nil
 }
 
-const listFavoriteNotes = `-- name: ListFavoriteNotes :many
-select id, title, note, archive, favorite, created_at, modified_at, tags
-from notes
-where favorite = TRUE
-order by modified_at desc
+const listTrashedAttachments = `-- name: ListTrashedAttachments :many
+select attachments.id
+from attachments
+    join notes on notes.id = attachments.note_id
+where notes.deleted_at < $1::timestamptz
 `
 
-func (q *Queries) ListFavoriteNotes(ctx context.Context) ([]Note, error) {
-	rows, err := q.db.Query(ctx, listFavoriteNotes)
+func (q *Queries) ListTrashedAttachments(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := q.db.Query(ctx, listTrashedAttachments, before)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Note
+	var items []string
 	for rows.Next() {
-		var i Note
-		if err := rows.Scan(
-			&i.ID,
-			&i.Title,
-			&i.Note,
-			&i.Archive,
-			&i.Favorite,
-			&i.CreatedAt,
-			&i.ModifiedAt,
-			&i.Tags,
-		); err != nil {
+		var id string
+		if err := rows.Scan(&id); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, id)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -322,15 +1778,15 @@ func (q *Queries) ListFavoriteNotes(ctx context.Context) ([]Note, error) {
 	return items, nil
 }
 
-const listNotes = `-- name: ListNotes :many
-select id, title, note, archive, favorite, created_at, modified_at, tags
+const listTrashedNotes = `-- name: ListTrashedNotes :many
+select id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
 from notes
-where archive != TRUE
-order by created_at desc
+where deleted_at is not null
+order by deleted_at desc
 `
 
-func (q *Queries) ListNotes(ctx context.Context) ([]Note, error) {
-	rows, err := q.db.Query(ctx, listNotes)
+func (q *Queries) ListTrashedNotes(ctx context.Context) ([]Note, error) {
+	rows, err := q.db.Query(ctx, listTrashedNotes)
 	if err != nil {
 		return nil, err
 	}
@@ -347,6 +1803,16 @@ func (q *Queries) ListNotes(ctx context.Context) ([]Note, error) {
 			&i.CreatedAt,
 			&i.ModifiedAt,
 			&i.Tags,
+			&i.DeletedAt,
+			&i.NotebookID,
+			&i.IsTemplate,
+			&i.DailyDate,
+			&i.RemindAt,
+			&i.ReminderSentAt,
+			&i.ExplicitTags,
+			&i.SearchLanguage,
+			&i.ReminderAttempts,
+			&i.ReminderRetryAt,
 		); err != nil {
 			return nil, err
 		}
@@ -358,12 +1824,92 @@ func (q *Queries) ListNotes(ctx context.Context) ([]Note, error) {
 	return items, nil
 }
 
+const moveNoteAttachments = `-- name: MoveNoteAttachments :exec
+update attachments
+set note_id = $1
+where note_id = $2
+`
+
+type MoveNoteAttachmentsParams struct {
+	ToID   string
+	FromID string
+}
+
+func (q *Queries) MoveNoteAttachments(ctx context.Context, arg MoveNoteAttachmentsParams) error {
+	_, err := q.db.Exec(ctx, moveNoteAttachments, arg.ToID, arg.FromID)
+	return err
+}
+
+const moveNoteRedirects = `-- name: MoveNoteRedirects :exec
+update note_redirects
+set note_id = $1
+where note_id = $2
+`
+
+type MoveNoteRedirectsParams struct {
+	ToID   string
+	FromID string
+}
+
+// Point the redirects to a note at another note, for when the note is merged into it
+func (q *Queries) MoveNoteRedirects(ctx context.Context, arg MoveNoteRedirectsParams) error {
+	_, err := q.db.Exec(ctx, moveNoteRedirects, arg.ToID, arg.FromID)
+	return err
+}
+
+const moveNotebookChildren = `-- name: MoveNotebookChildren :exec
+update notebooks
+set parent_id = $1
+where parent_id = $2::text
+`
+
+type MoveNotebookChildrenParams struct {
+	ParentID *string
+	ID       string
+}
+
+func (q *Queries) MoveNotebookChildren(ctx context.Context, arg MoveNotebookChildrenParams) error {
+	_, err := q.db.Exec(ctx, moveNotebookChildren, arg.ParentID, arg.ID)
+	return err
+}
+
+const moveNotebookNotes = `-- name: MoveNotebookNotes :exec
+update notes
+set notebook_id = $1
+where notebook_id = $2::text
+`
+
+type MoveNotebookNotesParams struct {
+	ParentID *string
+	ID       string
+}
+
+func (q *Queries) MoveNotebookNotes(ctx context.Context, arg MoveNotebookNotesParams) error {
+	_, err := q.db.Exec(ctx, moveNotebookNotes, arg.ParentID, arg.ID)
+	return err
+}
+
+const purgeTrashedNotes = `-- name: PurgeTrashedNotes :execrows
+delete from notes
+where deleted_at < $1::timestamptz
+`
+
+func (q *Queries) PurgeTrashedNotes(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeTrashedNotes, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const randomNote = `-- name: RandomNote :one
-SELECT id, title, note, archive, favorite, created_at, modified_at, tags
-FROM notes OFFSET floor(
+SELECT id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
+FROM notes
+WHERE deleted_at IS NULL OFFSET floor(
         random() * (
             select count(*)
             from notes
+            where deleted_at is null
         )
     )
 limit 1
@@ -381,61 +1927,101 @@ func (q *Queries) RandomNote(ctx context.Context) (Note, error) {
 		&i.CreatedAt,
 		&i.ModifiedAt,
 		&i.Tags,
+		&i.DeletedAt,
+		&i.NotebookID,
+		&i.IsTemplate,
+		&i.DailyDate,
+		&i.RemindAt,
+		&i.ReminderSentAt,
+		&i.ExplicitTags,
+		&i.SearchLanguage,
+		&i.ReminderAttempts,
+		&i.ReminderRetryAt,
 	)
 	return i, err
 }
 
-const searchNotes = `-- name: SearchNotes :many
-SELECT id, title, note, archive, favorite, created_at, modified_at, tags
-FROM notes
-WHERE (
-        $1::text = ''
-        OR ('id' || ' ' || title || ' ' || note) ILIKE '%' || $1::text || '%'
+const renameTag = `-- name: RenameTag :exec
+update tags
+set name = $1::text,
+    modified_at = NOW()
+where name = $2::text
+    and not exists (
+        select 1
+        from tags
+        where name = $1::text
     )
-    AND (
-        $2::text [] = '{""}' -- This matches the Go []string{""}
-        OR $2::text [] = '{}'
-        OR tags @> $2::text []
+`
+
+type RenameTagParams struct {
+	ToName   string
+	FromName string
+}
+
+func (q *Queries) RenameTag(ctx context.Context, arg RenameTagParams) error {
+	_, err := q.db.Exec(ctx, renameTag, arg.ToName, arg.FromName)
+	return err
+}
+
+const resolveWikiLink = `-- name: ResolveWikiLink :one
+select id,
+    title
+from notes
+where (
+        id = $1::text
+        or lower(title) = lower($1::text)
     )
-    AND (archive = $3::bool)
-    AND (
-        favorite = $4::bool
-        OR $4::bool = FALSE
+    and deleted_at is null
+order by id = $1::text desc,
+    created_at
+limit 1
+`
+
+type ResolveWikiLinkRow struct {
+	ID    string
+	Title string
+}
+
+func (q *Queries) ResolveWikiLink(ctx context.Context, target string) (ResolveWikiLinkRow, error) {
+	row := q.db.QueryRow(ctx, resolveWikiLink, target)
+	var i ResolveWikiLinkRow
+	err := row.Scan(&i.ID, &i.Title)
+	return i, err
+}
+
+const resolveWikiLinks = `-- name: ResolveWikiLinks :many
+select distinct on (targets.target) targets.target,
+    notes.id,
+    notes.title
+from unnest($1::text []) as targets(target)
+    join notes on (
+        notes.id = targets.target
+        or lower(notes.title) = lower(targets.target)
     )
-ORDER BY created_at DESC
+    and notes.deleted_at is null
+order by targets.target,
+    notes.id = targets.target desc,
+    notes.created_at
 `
 
-type SearchNotesParams struct {
-	Query     string
-	Tags      []string
-	Archived  bool
-	Favorites bool
+type ResolveWikiLinksRow struct {
+	Target string
+	ID     string
+	Title  string
 }
 
-func (q *Queries) SearchNotes(ctx context.Context, arg SearchNotesParams) ([]Note, error) {
-	rows, err := q.db.Query(ctx, searchNotes,
-		arg.Query,
-		arg.Tags,
-		arg.Archived,
-		arg.Favorites,
-	)
+// Resolve the wiki link targets to notes like ResolveWikiLink, with one row for each
+// target that resolves to a note
+func (q *Queries) ResolveWikiLinks(ctx context.Context, targets []string) ([]ResolveWikiLinksRow, error) {
+	rows, err := q.db.Query(ctx, resolveWikiLinks, targets)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Note
+	var items []ResolveWikiLinksRow
 	for rows.Next() {
-		var i Note
-		if err := rows.Scan(
-			&i.ID,
-			&i.Title,
-			&i.Note,
-			&i.Archive,
-			&i.Favorite,
-			&i.CreatedAt,
-			&i.ModifiedAt,
-			&i.Tags,
-		); err != nil {
+		var i ResolveWikiLinksRow
+		if err := rows.Scan(&i.Target, &i.ID, &i.Title); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -446,6 +2032,194 @@ func (q *Queries) SearchNotes(ctx context.Context, arg SearchNotesParams) ([]Not
 	return items, nil
 }
 
+const restoreNote = `-- name: RestoreNote :execrows
+update notes
+set deleted_at = NULL
+where id = $1
+    and deleted_at is not null
+`
+
+func (q *Queries) RestoreNote(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.Exec(ctx, restoreNote, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const saveAttachmentData = `-- name: SaveAttachmentData :exec
+insert into attachment_data (attachment_id, data)
+values ($1, $2) on conflict (attachment_id) do
+update
+set data = excluded.data
+`
+
+type SaveAttachmentDataParams struct {
+	AttachmentID string
+	Data         []byte
+}
+
+func (q *Queries) SaveAttachmentData(ctx context.Context, arg SaveAttachmentDataParams) error {
+	_, err := q.db.Exec(ctx, saveAttachmentData, arg.AttachmentID, arg.Data)
+	return err
+}
+
+const saveNoteDraft = `-- name: SaveNoteDraft :exec
+insert into note_drafts (id, note_id, title, note, tags, modified_at)
+values ($1, $2, $3, $4, $5, NOW()) on conflict (id) do
+update
+set title = excluded.title,
+    note = excluded.note,
+    tags = excluded.tags,
+    modified_at = excluded.modified_at
+where note_drafts.note_id is not distinct from excluded.note_id
+`
+
+type SaveNoteDraftParams struct {
+	ID     string
+	NoteID *string
+	Title  string
+	Note   string
+	Tags   string
+}
+
+func (q *Queries) SaveNoteDraft(ctx context.Context, arg SaveNoteDraftParams) error {
+	_, err := q.db.Exec(ctx, saveNoteDraft,
+		arg.ID,
+		arg.NoteID,
+		arg.Title,
+		arg.Note,
+		arg.Tags,
+	)
+	return err
+}
+
+const saveTag = `-- name: SaveTag :one
+insert into tags (name, description, color, pinned)
+values ($1, $2, $3, $4) on conflict (name) do
+update
+set description = excluded.description,
+    color = excluded.color,
+    pinned = excluded.pinned,
+    modified_at = NOW()
+returning name, description, color, pinned, modified_at
+`
+
+type SaveTagParams struct {
+	Name        string
+	Description string
+	Color       string
+	Pinned      bool
+}
+
+func (q *Queries) SaveTag(ctx context.Context, arg SaveTagParams) (Tag, error) {
+	row := q.db.QueryRow(ctx, saveTag,
+		arg.Name,
+		arg.Description,
+		arg.Color,
+		arg.Pinned,
+	)
+	var i Tag
+	err := row.Scan(
+		&i.Name,
+		&i.Description,
+		&i.Color,
+		&i.Pinned,
+		&i.ModifiedAt,
+	)
+	return i, err
+}
+
+const setNoteTags = `-- name: SetNoteTags :execrows
+update notes
+set tags = note_tags.tags
+from jsonb_to_recordset($1::jsonb) as note_tags(id text, tags text [], modified_at timestamptz)
+where notes.id = note_tags.id
+    and notes.modified_at = note_tags.modified_at
+`
+
+func (q *Queries) SetNoteTags(ctx context.Context, notes []byte) (int64, error) {
+	result, err := q.db.Exec(ctx, setNoteTags, notes)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setSimilarityThreshold = `-- name: SetSimilarityThreshold :exec
+select set_config(
+        'pg_trgm.similarity_threshold',
+        $1::real::text,
+        true
+    )
+`
+
+// Set the pg_trgm similarity threshold for the % operator until the end of the transaction
+func (q *Queries) SetSimilarityThreshold(ctx context.Context, threshold float32) error {
+	_, err := q.db.Exec(ctx, setSimilarityThreshold, threshold)
+	return err
+}
+
+const snoozeReminder = `-- name: SnoozeReminder :execrows
+update notes
+set remind_at = $1::timestamptz,
+    reminder_sent_at = NULL,
+    reminder_attempts = 0,
+    reminder_retry_at = NULL
+where id = $2::text
+    and deleted_at is null
+`
+
+type SnoozeReminderParams struct {
+	RemindAt time.Time
+	ID       string
+}
+
+func (q *Queries) SnoozeReminder(ctx context.Context, arg SnoozeReminderParams) (int64, error) {
+	result, err := q.db.Exec(ctx, snoozeReminder, arg.RemindAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const trashNote = `-- name: TrashNote :execrows
+update notes
+set deleted_at = NOW()
+where id = $1
+    and deleted_at is null
+`
+
+func (q *Queries) TrashNote(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.Exec(ctx, trashNote, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const unarchiveNote = `-- name: UnarchiveNote :exec
+update notes
+set archive = FALSE
+where id = $1
+`
+
+func (q *Queries) UnarchiveNote(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, unarchiveNote, id)
+	return err
+}
+
+const unfavoriteNote = `-- name: UnfavoriteNote :exec
+update notes
+set favorite = FALSE
+where id = $1
+`
+
+func (q *Queries) UnfavoriteNote(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, unfavoriteNote, id)
+	return err
+}
+
 const updateNote = `-- name: UpdateNote :one
 update notes
 set title = $2,
@@ -454,19 +2228,44 @@ set title = $2,
     favorite = $5,
     created_at = $6,
     tags = $7,
+    notebook_id = $8,
+    is_template = $9,
+    remind_at = $10,
+    reminder_sent_at = CASE
+        WHEN remind_at IS DISTINCT
+        FROM $10 THEN NULL
+        ELSE reminder_sent_at
+    END,
+    reminder_attempts = CASE
+        WHEN remind_at IS DISTINCT
+        FROM $10 THEN 0
+        ELSE reminder_attempts
+    END,
+    reminder_retry_at = CASE
+        WHEN remind_at IS DISTINCT
+        FROM $10 THEN NULL
+        ELSE reminder_retry_at
+    END,
+    explicit_tags = $12,
     modified_at = NOW()
 where id = $1
-returning id, title, note, archive, favorite, created_at, modified_at, tags
+    and modified_at = $11
+returning id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
 `
 
 type UpdateNoteParams struct {
-	ID        string
-	Title     string
-	Note      string
-	Archive   bool
-	Favorite  bool
-	CreatedAt time.Time
-	Tags      []string
+	ID           string
+	Title        string
+	Note         string
+	Archive      bool
+	Favorite     bool
+	CreatedAt    time.Time
+	Tags         []string
+	NotebookID   *string
+	IsTemplate   bool
+	RemindAt     *time.Time
+	ModifiedAt   time.Time
+	ExplicitTags []string
 }
 
 func (q *Queries) UpdateNote(ctx context.Context, arg UpdateNoteParams) (Note, error) {
@@ -478,6 +2277,11 @@ func (q *Queries) UpdateNote(ctx context.Context, arg UpdateNoteParams) (Note, e
 		arg.Favorite,
 		arg.CreatedAt,
 		arg.Tags,
+		arg.NotebookID,
+		arg.IsTemplate,
+		arg.RemindAt,
+		arg.ModifiedAt,
+		arg.ExplicitTags,
 	)
 	var i Note
 	err := row.Scan(
@@ -489,16 +2293,42 @@ func (q *Queries) UpdateNote(ctx context.Context, arg UpdateNoteParams) (Note, e
 		&i.CreatedAt,
 		&i.ModifiedAt,
 		&i.Tags,
+		&i.DeletedAt,
+		&i.NotebookID,
+		&i.IsTemplate,
+		&i.DailyDate,
+		&i.RemindAt,
+		&i.ReminderSentAt,
+		&i.ExplicitTags,
+		&i.SearchLanguage,
+		&i.ReminderAttempts,
+		&i.ReminderRetryAt,
 	)
 	return i, err
 }
 
+const updateNoteExplicitTags = `-- name: UpdateNoteExplicitTags :exec
+update notes
+set explicit_tags = $2
+where id = $1
+`
+
+type UpdateNoteExplicitTagsParams struct {
+	ID           string
+	ExplicitTags []string
+}
+
+func (q *Queries) UpdateNoteExplicitTags(ctx context.Context, arg UpdateNoteExplicitTagsParams) error {
+	_, err := q.db.Exec(ctx, updateNoteExplicitTags, arg.ID, arg.ExplicitTags)
+	return err
+}
+
 const updateNoteTags = `-- name: UpdateNoteTags :one
 update notes
 set tags = $2,
     modified_at = NOW()
 where id = $1
-returning id, title, note, archive, favorite, created_at, modified_at, tags
+returning id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
 `
 
 type UpdateNoteTagsParams struct {
@@ -518,6 +2348,123 @@ func (q *Queries) UpdateNoteTags(ctx context.Context, arg UpdateNoteTagsParams)
 		&i.CreatedAt,
 		&i.ModifiedAt,
 		&i.Tags,
+		&i.DeletedAt,
+		&i.NotebookID,
+		&i.IsTemplate,
+		&i.DailyDate,
+		&i.RemindAt,
+		&i.ReminderSentAt,
+		&i.ExplicitTags,
+		&i.SearchLanguage,
+		&i.ReminderAttempts,
+		&i.ReminderRetryAt,
+	)
+	return i, err
+}
+
+const updateNoteText = `-- name: UpdateNoteText :one
+update notes
+set note = $2,
+    modified_at = NOW()
+where id = $1
+    and deleted_at is null
+returning id, title, note, archive, favorite, created_at, modified_at, tags, deleted_at, notebook_id, is_template, daily_date, remind_at, reminder_sent_at, explicit_tags, search_language, reminder_attempts, reminder_retry_at
+`
+
+type UpdateNoteTextParams struct {
+	ID   string
+	Note string
+}
+
+func (q *Queries) UpdateNoteText(ctx context.Context, arg UpdateNoteTextParams) (Note, error) {
+	row := q.db.QueryRow(ctx, updateNoteText, arg.ID, arg.Note)
+	var i Note
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Note,
+		&i.Archive,
+		&i.Favorite,
+		&i.CreatedAt,
+		&i.ModifiedAt,
+		&i.Tags,
+		&i.DeletedAt,
+		&i.NotebookID,
+		&i.IsTemplate,
+		&i.DailyDate,
+		&i.RemindAt,
+		&i.ReminderSentAt,
+		&i.ExplicitTags,
+		&i.SearchLanguage,
+		&i.ReminderAttempts,
+		&i.ReminderRetryAt,
+	)
+	return i, err
+}
+
+const updateNotebook = `-- name: UpdateNotebook :one
+update notebooks
+set name = $2,
+    parent_id = $3
+where id = $1
+returning id, parent_id, name, created_at
+`
+
+type UpdateNotebookParams struct {
+	ID       string
+	Name     string
+	ParentID *string
+}
+
+func (q *Queries) UpdateNotebook(ctx context.Context, arg UpdateNotebookParams) (Notebook, error) {
+	row := q.db.QueryRow(ctx, updateNotebook, arg.ID, arg.Name, arg.ParentID)
+	var i Notebook
+	err := row.Scan(
+		&i.ID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateSavedSearch = `-- name: UpdateSavedSearch :one
+update saved_searches
+set name = $2,
+    pinned = $3
+where id = $1
+returning id, name, query, pinned, created_at
+`
+
+type UpdateSavedSearchParams struct {
+	ID     string
+	Name   string
+	Pinned bool
+}
+
+func (q *Queries) UpdateSavedSearch(ctx context.Context, arg UpdateSavedSearchParams) (SavedSearch, error) {
+	row := q.db.QueryRow(ctx, updateSavedSearch, arg.ID, arg.Name, arg.Pinned)
+	var i SavedSearch
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Query,
+		&i.Pinned,
+		&i.CreatedAt,
 	)
 	return i, err
 }
+
+const updateSearchLanguage = `-- name: UpdateSearchLanguage :execrows
+update notes
+set search_language = $1::regconfig
+where search_language <> $1::regconfig
+`
+
+func (q *Queries) UpdateSearchLanguage(ctx context.Context, language string) (int64, error) {
+	result, err := q.db.Exec(ctx, updateSearchLanguage, language)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sglmr/go-notes/internal/search"
+)
+
+// Sort orders for SearchNotes
+const (
+	SortCreated   = "created"
+	SortModified  = "modified"
+	SortTitle     = "title"
+	SortRelevance = "relevance"
+)
+
+// SearchNotesParams are the parameters for SearchNotes. SearchNotes is written by hand
+// instead of generated by sqlc because its conditions come from a parsed search query.
+type SearchNotesParams struct {
+	Query      search.Query
+	Language   string
+	NotebookID string
+
+	// Sort is one of the Sort constants. Relevance sorts by created without text terms.
+	Sort string
+
+	// After is the cursor of the last note on the previous page, or nil for the first page
+	After *SearchCursor
+
+	// Limit is the most notes to return, or 0 for all of them
+	Limit int
+}
+
+// SearchCursor is a position in the search results: the sort value and ID of a note
+type SearchCursor struct {
+	Value string
+	ID    string
+}
+
+// String encodes the cursor for a URL
+func (c SearchCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Value + "\n" + c.ID))
+}
+
+// ParseSearchCursor decodes a cursor from SearchCursor.String
+func ParseSearchCursor(s string) (SearchCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return SearchCursor{}, fmt.Errorf("decode search cursor: %w", err)
+	}
+	value, id, ok := strings.Cut(string(b), "\n")
+	if !ok || id == "" {
+		return SearchCursor{}, errors.New("decode search cursor: missing note ID")
+	}
+	return SearchCursor{Value: value, ID: id}, nil
+}
+
+// Cursor returns the cursor of the row for the sort order of the search
+func (r SearchNotesRow) Cursor(arg SearchNotesParams) SearchCursor {
+	var value string
+	switch searchSort(arg) {
+	case SortModified:
+		value = r.Note.ModifiedAt.Format(time.RFC3339Nano)
+	case SortTitle:
+		value = r.Note.Title
+	case SortRelevance:
+		value = strconv.FormatFloat(float64(r.Rank), 'g', -1, 32)
+	default:
+		value = r.Note.CreatedAt.Format(time.RFC3339Nano)
+	}
+	return SearchCursor{Value: value, ID: r.Note.ID}
+}
+
+// searchSort returns the sort order of a search. Searches without text terms can't sort
+// by relevance, and searches with text terms sort by it unless they choose another order.
+func searchSort(arg SearchNotesParams) string {
+	switch {
+	case arg.Sort == SortCreated || arg.Sort == SortModified || arg.Sort == SortTitle:
+		return arg.Sort
+	case (arg.Sort == "" || arg.Sort == SortRelevance) && arg.Query.Text() != "":
+		return SortRelevance
+	}
+	return SortCreated
+}
+
+type SearchNotesRow struct {
+	Note    Note
+	Rank    float32
+	Snippet string
+}
+
+// searchNotesColumns are the columns of the notes table in the order of the Note struct fields
+const searchNotesColumns = `notes.id, notes.title, notes.note, notes.archive, notes.favorite, notes.created_at, notes.modified_at, notes.tags, notes.deleted_at, notes.notebook_id, notes.is_template, notes.daily_date, notes.remind_at, notes.reminder_sent_at, notes.explicit_tags, notes.search_language, notes.reminder_attempts, notes.reminder_retry_at`
+
+// searchNotesSQL returns the SQL and arguments to search for notes. The snippets mark the
+// matches with U+E000 and U+E001 so the note text can be escaped first.
+func searchNotesSQL(arg SearchNotesParams) (string, []any) {
+	where, compiled := searchNotesWhere(arg)
+
+	rank, snippet := "0", "''"
+	if compiled.TSQuery != "" {
+		rank = fmt.Sprintf("ts_rank(%s, %s)", search.Vector, compiled.TSQuery)
+		snippet = fmt.Sprintf(
+			`ts_headline(search_language, note, %s, 'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', MaxFragments=2, MinWords=10, MaxWords=30, FragmentDelimiter=" … "')`,
+			compiled.TSQuery,
+		)
+	}
+
+	// The sort column with the type of its cursor value, and the direction
+	column, cast, direction, compare := "created_at", "timestamptz", "DESC", "<"
+	switch searchSort(arg) {
+	case SortModified:
+		column = "modified_at"
+	case SortTitle:
+		column, cast, direction, compare = "title", "text", "ASC", ">"
+	case SortRelevance:
+		column, cast = "("+rank+")::real", "real"
+	}
+
+	// Continue after the cursor, comparing the ID when notes have the same sort value
+	args := compiled.Args
+	if arg.After != nil {
+		args = append(args, arg.After.Value, arg.After.ID)
+		where += fmt.Sprintf("\n    AND (%s, id) %s ($%d::%s, $%d::text)", column, compare, len(args)-1, cast, len(args))
+	}
+
+	limit := ""
+	if arg.Limit > 0 {
+		args = append(args, arg.Limit)
+		limit = fmt.Sprintf("\nLIMIT $%d", len(args))
+	}
+
+	sql := `SELECT ` + searchNotesColumns + `,
+    (` + rank + `)::real AS rank,
+    (` + snippet + `)::text AS snippet
+FROM notes
+` + where + `
+ORDER BY ` + column + ` ` + direction + `,
+    id ` + direction + limit
+	return sql, args
+}
+
+// searchNotesWhere returns the WHERE clause for the notes that match the search and the
+// compiled search query with all the arguments of the clause
+func searchNotesWhere(arg SearchNotesParams) (string, search.SQL) {
+	compiled := arg.Query.Compile(arg.Language)
+	args := compiled.Args
+
+	notebook := ""
+	if arg.NotebookID != "" {
+		args = append(args, arg.NotebookID)
+		notebook = fmt.Sprintf(`
+    AND notebook_id IN (
+        WITH RECURSIVE notebook_tree AS (
+            SELECT id
+            FROM notebooks
+            WHERE id = $%d::text
+            UNION ALL
+            SELECT notebooks.id
+            FROM notebooks
+                JOIN notebook_tree ON notebooks.parent_id = notebook_tree.id
+        )
+        SELECT id
+        FROM notebook_tree
+    )`, len(args))
+	}
+
+	compiled.Args = args
+	return `WHERE deleted_at IS NULL
+    AND ` + compiled.Where + notebook, compiled
+}
+
+// SearchNotes returns the notes that match a search query, the best matches first
+func (q *Queries) SearchNotes(ctx context.Context, arg SearchNotesParams) ([]SearchNotesRow, error) {
+	sql, args := searchNotesSQL(arg)
+	rows, err := q.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchNotesRow
+	for rows.Next() {
+		var i SearchNotesRow
+		if err := rows.Scan(
+			&i.Note.ID,
+			&i.Note.Title,
+			&i.Note.Note,
+			&i.Note.Archive,
+			&i.Note.Favorite,
+			&i.Note.CreatedAt,
+			&i.Note.ModifiedAt,
+			&i.Note.Tags,
+			&i.Note.DeletedAt,
+			&i.Note.NotebookID,
+			&i.Note.IsTemplate,
+			&i.Note.DailyDate,
+			&i.Note.RemindAt,
+			&i.Note.ReminderSentAt,
+			&i.Note.ExplicitTags,
+			&i.Note.SearchLanguage,
+			&i.Note.ReminderAttempts,
+			&i.Note.ReminderRetryAt,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// TagCount is a tag with the number of notes that have the tag and the number of notes
+// that have the tag or any of its nested tags
+type TagCount struct {
+	TagName   string
+	NoteCount int64
+	Total     int64
+}
+
+// searchTagCountsSQL returns the SQL and arguments to count the tags of the notes that
+// match a search. Each parent/child tag is also counted for its parent tags, and a note
+// with several nested tags of a parent is only counted once in the parent's total.
+func searchTagCountsSQL(arg SearchNotesParams) (string, []any) {
+	where, compiled := searchNotesWhere(arg)
+	sql := `SELECT prefix AS tag_name,
+    count(DISTINCT notes.id) FILTER (
+        WHERE tag = prefix
+    ) AS note_count,
+    count(DISTINCT notes.id) AS total
+FROM notes,
+    unnest(tags) AS tag,
+    LATERAL (
+        SELECT array_to_string((string_to_array(tag, '/'))[1:depth], '/') AS prefix
+        FROM generate_series(1, cardinality(string_to_array(tag, '/'))) AS depth
+    ) AS prefixes
+` + where + `
+GROUP BY prefix
+ORDER BY prefix`
+	return sql, compiled.Args
+}
+
+// SearchTagCounts returns the tags of the notes that match a search and their parent tags
+// with the number of matching notes for each tag
+func (q *Queries) SearchTagCounts(ctx context.Context, arg SearchNotesParams) ([]TagCount, error) {
+	sql, args := searchTagCountsSQL(arg)
+	rows, err := q.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TagCount
+	for rows.Next() {
+		var i TagCount
+		if err := rows.Scan(&i.TagName, &i.NoteCount, &i.Total); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// CountSearchNotes returns the number of notes that match a search
+func (q *Queries) CountSearchNotes(ctx context.Context, arg SearchNotesParams) (int64, error) {
+	where, compiled := searchNotesWhere(arg)
+	row := q.db.QueryRow(ctx, `SELECT count(*)
+FROM notes
+`+where, compiled.Args...)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
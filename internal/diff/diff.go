@@ -0,0 +1,145 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Op is the type of change for a segment of a diff
+type Op string
+
+const (
+	// Different Op types
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Edit is a single segment of text in a diff
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// Lines returns the line by line differences to turn text a into text b.
+func Lines(a, b string) []Edit {
+	return compute(splitLines(a), splitLines(b))
+}
+
+// Words returns the word by word differences to turn text a into text b.
+// Whitespace is kept in the edits, so joining the Equal and Insert edits rebuilds b.
+func Words(a, b string) []Edit {
+	edits := compute(splitWords(a), splitWords(b))
+
+	// Merge neighboring edits of the same type into a single edit
+	merged := []Edit{}
+	for _, e := range edits {
+		if n := len(merged); n > 0 && merged[n-1].Op == e.Op {
+			merged[n-1].Text += e.Text
+			continue
+		}
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// HasChanges returns true when any of the edits is an insert or a delete
+func HasChanges(edits []Edit) bool {
+	for _, e := range edits {
+		if e.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// compute finds the longest common subsequence of a and b and returns
+// the edits needed to turn a into b.
+func compute(a, b []string) []Edit {
+	// Skip over the common prefix and suffix to keep the table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := []Edit{}
+	for _, s := range a[:prefix] {
+		edits = append(edits, Edit{Op: Equal, Text: s})
+	}
+
+	// Build the longest common subsequence table for the middle section
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	table := make([][]int32, len(ma)+1)
+	for i := range table {
+		table[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	// Walk the table to collect the edits
+	i, j := 0, 0
+	for i < len(ma) && j < len(mb) {
+		switch {
+		case ma[i] == mb[j]:
+			edits = append(edits, Edit{Op: Equal, Text: ma[i]})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			edits = append(edits, Edit{Op: Delete, Text: ma[i]})
+			i++
+		default:
+			edits = append(edits, Edit{Op: Insert, Text: mb[j]})
+			j++
+		}
+	}
+	for ; i < len(ma); i++ {
+		edits = append(edits, Edit{Op: Delete, Text: ma[i]})
+	}
+	for ; j < len(mb); j++ {
+		edits = append(edits, Edit{Op: Insert, Text: mb[j]})
+	}
+
+	for _, s := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Op: Equal, Text: s})
+	}
+
+	return edits
+}
+
+// splitLines splits text into lines without the line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(text, "\n")
+}
+
+// splitWords splits text into alternating runs of whitespace and non-whitespace
+func splitWords(text string) []string {
+	words := []string{}
+	start := 0
+	inSpace := false
+	for i, r := range text {
+		if i > start && unicode.IsSpace(r) != inSpace {
+			words = append(words, text[start:i])
+			start = i
+		}
+		inSpace = unicode.IsSpace(r)
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sglmr/go-notes/internal/assert"
+)
+
+func TestLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []Edit
+	}{
+		{
+			name: "Both empty",
+			a:    "",
+			b:    "",
+			want: []Edit{},
+		},
+		{
+			name: "No changes",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: []Edit{{Equal, "one"}, {Equal, "two"}},
+		},
+		{
+			name: "Added line",
+			a:    "one\nthree",
+			b:    "one\ntwo\nthree",
+			want: []Edit{{Equal, "one"}, {Insert, "two"}, {Equal, "three"}},
+		},
+		{
+			name: "Removed line",
+			a:    "one\ntwo\nthree",
+			b:    "one\nthree",
+			want: []Edit{{Equal, "one"}, {Delete, "two"}, {Equal, "three"}},
+		},
+		{
+			name: "Changed line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []Edit{{Equal, "one"}, {Delete, "two"}, {Insert, "2"}, {Equal, "three"}},
+		},
+		{
+			name: "From empty",
+			a:    "",
+			b:    "one",
+			want: []Edit{{Insert, "one"}},
+		},
+		{
+			name: "Windows line endings",
+			a:    "one\r\ntwo",
+			b:    "one\ntwo",
+			want: []Edit{{Equal, "one"}, {Equal, "two"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.EqualSlices(t, tt.want, Lines(tt.a, tt.b))
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []Edit
+	}{
+		{
+			name: "No changes",
+			a:    "the quick fox",
+			b:    "the quick fox",
+			want: []Edit{{Equal, "the quick fox"}},
+		},
+		{
+			name: "Changed word",
+			a:    "the quick fox",
+			b:    "the slow fox",
+			want: []Edit{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " fox"}},
+		},
+		{
+			name: "Added words",
+			a:    "the fox",
+			b:    "the brown fox",
+			want: []Edit{{Equal, "the "}, {Insert, "brown "}, {Equal, "fox"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := Words(tt.a, tt.b)
+			assert.EqualSlices(t, tt.want, got)
+
+			// Joining the equal and inserted text should rebuild b
+			var b strings.Builder
+			for _, e := range got {
+				if e.Op != Delete {
+					b.WriteString(e.Text)
+				}
+			}
+			assert.Equal(t, tt.b, b.String())
+		})
+	}
+}
+
+func TestHasChanges(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, false, HasChanges(Lines("a\nb", "a\nb")))
+	assert.Equal(t, true, HasChanges(Lines("a\nb", "a\nc")))
+	assert.Equal(t, false, HasChanges([]Edit{}))
+}
@@ -11,11 +11,15 @@ import (
 	"unicode"
 
 	chroma "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/sglmr/go-notes/internal/tasklist"
+	"github.com/sglmr/go-notes/internal/wikilink"
 	"github.com/yuin/goldmark"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 )
@@ -38,6 +42,8 @@ var TemplateFuncs = template.FuncMap{
 	"slugify":        slugify,
 	"safeHTML":       safeHTML,
 	"markdownToHTML": markdownToHTML,
+	"highlight":      highlight,
+	"excerpt":        excerpt,
 
 	// Slice functions
 	"join": strings.Join,
@@ -45,6 +51,7 @@ var TemplateFuncs = template.FuncMap{
 	// Number functions
 	"formatInt":   formatInt,
 	"formatFloat": formatFloat,
+	"formatBytes": formatBytes,
 
 	// Boolean functions
 	"yesno": yesno,
@@ -54,7 +61,9 @@ var TemplateFuncs = template.FuncMap{
 	"urlDelParam": urlDelParam,
 
 	// generic functions
-	"stringContains": strings.Contains,
+	"stringContains":  strings.Contains,
+	"stringHasPrefix": strings.HasPrefix,
+	"stringRepeat":    strings.Repeat,
 }
 
 // timeInLocation returns a time in a different location timezone
@@ -97,6 +106,57 @@ func slugify(s string) string {
 	return buf.String()
 }
 
+// highlight escapes a search snippet and wraps the matches in <mark> tags. The search query
+// marks the start and end of each match with the U+E000 and U+E001 private use characters.
+func highlight(snippet string) template.HTML {
+	r := strings.NewReplacer("\ue000", "<mark>", "\ue001", "</mark>")
+	return template.HTML(r.Replace(template.HTMLEscapeString(snippet)))
+}
+
+// excerpt returns the plain text of the markdown in content, without the formatting and
+// code blocks, shortened to at most n characters
+func excerpt(content string, n int) string {
+	source := []byte(content)
+	doc := goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser().Parse(text.NewReader(source))
+
+	var buf strings.Builder
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch node := node.(type) {
+		case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if entering {
+				buf.Write(node.Segment.Value(source))
+				if node.SoftLineBreak() || node.HardLineBreak() {
+					buf.WriteByte(' ')
+				}
+			}
+		case *ast.String:
+			if entering {
+				buf.Write(node.Value)
+			}
+		}
+
+		// Separate the text of blocks like paragraphs and table cells
+		if !entering && node.Type() == ast.TypeBlock {
+			buf.WriteByte(' ')
+		}
+		return ast.WalkContinue, nil
+	})
+
+	plain := []rune(strings.Join(strings.Fields(buf.String()), " "))
+	if len(plain) <= n {
+		return string(plain)
+	}
+
+	// Shorten to the last whole word, or cut a word that is too long
+	short := string(plain[:n+1])
+	if i := strings.LastIndexByte(short, ' '); i > 0 {
+		return short[:i] + "…"
+	}
+	return string(plain[:n]) + "…"
+}
+
 func safeHTML(s string) template.HTML {
 	return template.HTML(s)
 }
@@ -115,6 +175,20 @@ func formatFloat(f float64, dp int) string {
 	return printer.Sprintf(format, f)
 }
 
+// formatBytes returns a human readable file size, like 1.5 MB
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return printer.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return printer.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGT"[exp])
+}
+
 func yesno(b bool) string {
 	if b {
 		return "Yes"
@@ -171,12 +245,15 @@ func toInt64(i any) (int64, error) {
 	return 0, fmt.Errorf("unable to convert type %T to int", i)
 }
 
-// markdownToHTML converts a string of Markdown into an HTML string
-func markdownToHTML(content string) template.HTML {
+// markdownToHTML converts a string of Markdown into an HTML string. The resolver looks up
+// the notes for [[wiki links]], which link to new notes when it is nil.
+func markdownToHTML(resolver wikilink.Resolver, content string) template.HTML {
 	md := goldmark.New(
 		goldmark.WithExtensions(
 			extension.GFM,
 			extension.Footnote,
+			&wikilink.Extension{Resolver: resolver},
+			&tasklist.Extension{},
 			highlighting.NewHighlighting(
 				highlighting.WithStyle("catppuccin-frappe"),
 				highlighting.WithFormatOptions(
@@ -1,6 +1,7 @@
 package funcs
 
 import (
+	"html/template"
 	"testing"
 
 	"github.com/sglmr/go-notes/internal/assert"
@@ -38,3 +39,74 @@ func TestSlugify(t *testing.T) {
 		})
 	}
 }
+
+// TestFormatBytes runs a series of tests on the formatBytes function
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input int64
+		want  string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 * 1024 * 1024, "5.0 MB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GB"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, formatBytes(test.input))
+		})
+	}
+}
+
+// TestHighlight runs a series of tests on the highlight function
+func TestHighlight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  template.HTML
+	}{
+		{"no matches", "no matches"},
+		{"a \ue000match\ue001 and \ue000another\ue001", "a <mark>match</mark> and <mark>another</mark>"},
+		{"<script>\ue000alert\ue001</script>", "&lt;script&gt;<mark>alert</mark>&lt;/script&gt;"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, highlight(test.input))
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		n       int
+		want    string
+	}{
+		{"Plain text", "Some plain text", 100, "Some plain text"},
+		{"Formatting", "# Title\n\nSome **bold** and [a link](https://example.com).\n\n* one\n* two", 100, "Title Some bold and a link. one two"},
+		{"Code blocks", "Before\n\n```go\nfmt.Println()\n```\n\nAfter `code`", 100, "Before After code"},
+		{"Tables", "| A | B |\n|---|---|\n| 1 | 2 |", 100, "A B 1 2"},
+		{"Shortened", "one two three four", 9, "one two…"},
+		{"Shortened after a word", "one two three four", 7, "one two…"},
+		{"Long word", "onetwothree", 3, "one…"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, excerpt(test.content, test.n))
+		})
+	}
+}
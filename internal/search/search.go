@@ -0,0 +1,281 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Term fields
+const (
+	FieldText   = ""
+	FieldTag    = "tag"
+	FieldIs     = "is"
+	FieldBefore = "before"
+	FieldAfter  = "after"
+	FieldTitle  = "title"
+)
+
+// Values for the is: field
+const (
+	IsFavorite = "favorite"
+	IsArchived = "archived"
+)
+
+// Term is one part of a search query, like tag:work, -is:archived or "a phrase"
+type Term struct {
+	Field  string
+	Value  string
+	Phrase bool
+	Negate bool
+
+	// Time is the start of the day in a before: or after: term
+	Time time.Time
+}
+
+// Query is a parsed search query. A note has to match every term and, if there are any,
+// at least one of the Any terms. Text terms in Any are ignored.
+type Query struct {
+	Terms []Term
+	Any   []Term
+}
+
+// Error is a problem with a search query at a position (in characters) of the input
+type Error struct {
+	Pos int
+	Msg string
+}
+
+// Error returns the error message with the position of the problem
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at character %d", e.Msg, e.Pos+1)
+}
+
+//=============================================================================
+// Parser
+//=============================================================================
+
+// Parse parses a search query like `tag:work -is:archived before:2025-01-01 "weekly sync" -draft`.
+// Dates are days in the time location. Unknown field prefixes, like http:, are searched as text.
+func Parse(input string, loc *time.Location) (Query, error) {
+	query := Query{Terms: []Term{}}
+
+	pos := 0
+	for {
+		// Skip the space between terms, which can be more than one byte like U+3000
+		for pos < len(input) {
+			r, size := utf8.DecodeRuneInString(input[pos:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			pos += size
+		}
+		if pos >= len(input) {
+			return query, nil
+		}
+
+		start := pos
+		term := Term{}
+		if input[pos] == '-' {
+			term.Negate = true
+			pos++
+		}
+
+		// Look for a field: prefix
+		if field, ok := fieldAt(input, pos); ok {
+			term.Field = field
+			pos += len(field) + 1
+		}
+
+		// Read the quoted or plain value
+		if pos < len(input) && input[pos] == '"' {
+			end := strings.IndexByte(input[pos+1:], '"')
+			if end < 0 {
+				return query, &Error{Pos: utf8.RuneCountInString(input[:pos]), Msg: "missing closing quote"}
+			}
+			term.Value = input[pos+1 : pos+1+end]
+			term.Phrase = true
+			pos += end + 2
+		} else {
+			end := strings.IndexFunc(input[pos:], unicode.IsSpace)
+			if end < 0 {
+				end = len(input) - pos
+			}
+			term.Value = input[pos : pos+end]
+			pos += end
+		}
+		term.Value = strings.TrimSpace(term.Value)
+
+		if err := checkTerm(&term, loc); err != nil {
+			return query, &Error{Pos: utf8.RuneCountInString(input[:start]), Msg: err.Error()}
+		}
+
+		// A lone dash or empty quotes don't search for anything
+		if term.Field == FieldText && term.Value == "" {
+			continue
+		}
+		query.Terms = append(query.Terms, term)
+	}
+}
+
+// fieldAt returns the field of a field:value term that starts at pos
+func fieldAt(input string, pos int) (string, bool) {
+	for _, field := range []string{FieldTag, FieldIs, FieldBefore, FieldAfter, FieldTitle} {
+		if strings.HasPrefix(input[pos:], field+":") {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// checkTerm checks the value of a term and sets the time for before: and after: terms
+func checkTerm(term *Term, loc *time.Location) error {
+	if term.Field != FieldText && term.Value == "" {
+		return fmt.Errorf("%s: needs a value", term.Field)
+	}
+
+	switch term.Field {
+	case FieldIs:
+		if term.Value != IsFavorite && term.Value != IsArchived {
+			return fmt.Errorf("unknown is:%s, use is:%s or is:%s", term.Value, IsFavorite, IsArchived)
+		}
+	case FieldBefore, FieldAfter:
+		t, err := time.ParseInLocation("2006-01-02", term.Value, loc)
+		if err != nil {
+			return fmt.Errorf("%s:%s is not a date like 2025-01-31", term.Field, term.Value)
+		}
+		term.Time = t
+	}
+	return nil
+}
+
+//=============================================================================
+// SQL
+//=============================================================================
+
+// Vector is the full text search vector of a note. It is the expression of the
+// notes_search_vector_idx index, so searches need to use it as is to use the index.
+const Vector = `(setweight(to_tsvector(search_language, title), 'A') || setweight(to_tsvector(search_language, note), 'B'))`
+
+// SQL is a search query compiled to SQL conditions on the notes table
+type SQL struct {
+	// Where is the SQL condition that notes must match, with $1, $2... placeholders
+	Where string
+
+	// TSQuery is the SQL tsquery of the text terms, or "" if the query has no text terms.
+	// It is for ranking the results and making snippets.
+	TSQuery string
+
+	// Args are the values of the placeholders
+	Args []any
+}
+
+// Text returns the text terms of the query in websearch_to_tsquery syntax
+func (q Query) Text() string {
+	words := []string{}
+	for _, term := range q.Terms {
+		if term.Field == FieldText {
+			words = append(words, term.text())
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// text returns the value of a term in websearch_to_tsquery syntax
+func (t Term) text() string {
+	value := t.Value
+	if t.Phrase {
+		value = `"` + value + `"`
+	}
+	if t.Negate {
+		value = "-" + value
+	}
+	return value
+}
+
+// Compile compiles the query to SQL conditions on the notes table. The text searches use
+// the PostgreSQL text search configuration in language. Archived notes are only matched by
+// queries with an is:archived term.
+func (q Query) Compile(language string) SQL {
+	var sql SQL
+	arg := func(value any) string {
+		sql.Args = append(sql.Args, value)
+		return fmt.Sprintf("$%d", len(sql.Args))
+	}
+
+	conditions := []string{}
+	if text := q.Text(); text != "" {
+		textArg := arg(text)
+		sql.TSQuery = fmt.Sprintf("websearch_to_tsquery(%s::regconfig, %s::text)", arg(language), textArg)
+		conditions = append(conditions, fmt.Sprintf("(%s @@ %s OR id = %s::text)", Vector, sql.TSQuery, textArg))
+	}
+
+	archived := false
+	for _, term := range q.Terms {
+		if condition := term.condition(arg); condition != "" {
+			conditions = append(conditions, condition)
+		}
+		if term.Field == FieldIs && term.Value == IsArchived && !term.Negate {
+			archived = true
+		}
+	}
+
+	// The Any terms are one condition
+	anyConditions := []string{}
+	for _, term := range q.Any {
+		if condition := term.condition(arg); condition != "" {
+			anyConditions = append(anyConditions, condition)
+		}
+	}
+	if len(anyConditions) > 0 {
+		conditions = append(conditions, "("+strings.Join(anyConditions, " OR ")+")")
+	}
+
+	if !archived {
+		conditions = append(conditions, "NOT archive")
+	}
+
+	sql.Where = strings.Join(conditions, "\n    AND ")
+	return sql
+}
+
+// condition returns the SQL condition for a term, or "" for text terms. arg adds a value
+// to the arguments and returns its placeholder.
+func (t Term) condition(arg func(value any) string) string {
+	var condition string
+	switch t.Field {
+	case FieldTag:
+		// Match the tag or a tag nested inside it
+		value := arg(t.Value)
+		condition = fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM unnest(tags) AS tag WHERE tag = %s::text OR starts_with(tag, %s::text || '/'))",
+			value, value,
+		)
+	case FieldTitle:
+		value := t.Value
+		if t.Phrase {
+			value = `"` + value + `"`
+		}
+		condition = fmt.Sprintf(
+			"to_tsvector(search_language, title) @@ websearch_to_tsquery(search_language, %s::text)",
+			arg(value),
+		)
+	case FieldIs:
+		condition = "favorite"
+		if t.Value == IsArchived {
+			condition = "archive"
+		}
+	case FieldBefore:
+		condition = fmt.Sprintf("created_at < %s::timestamptz", arg(t.Time))
+	case FieldAfter:
+		condition = fmt.Sprintf("created_at >= %s::timestamptz", arg(t.Time.AddDate(0, 0, 1)))
+	default:
+		return ""
+	}
+	if t.Negate {
+		condition = "NOT (" + condition + ")"
+	}
+	return condition
+}
@@ -0,0 +1,184 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sglmr/go-notes/internal/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	day := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []Term
+	}{
+		{
+			name:  "Empty",
+			input: "  ",
+			want:  []Term{},
+		},
+		{
+			name:  "Words",
+			input: "bread  flour",
+			want:  []Term{{Value: "bread"}, {Value: "flour"}},
+		},
+		{
+			name:  "Excluded word",
+			input: "bread -rye",
+			want:  []Term{{Value: "bread"}, {Value: "rye", Negate: true}},
+		},
+		{
+			name:  "Phrase",
+			input: `"sourdough bread" -"whole wheat"`,
+			want: []Term{
+				{Value: "sourdough bread", Phrase: true},
+				{Value: "whole wheat", Phrase: true, Negate: true},
+			},
+		},
+		{
+			name:  "Tags",
+			input: "tag:work -tag:project/alpha",
+			want: []Term{
+				{Field: FieldTag, Value: "work"},
+				{Field: FieldTag, Value: "project/alpha", Negate: true},
+			},
+		},
+		{
+			name:  "Is",
+			input: "is:favorite -is:archived",
+			want: []Term{
+				{Field: FieldIs, Value: IsFavorite},
+				{Field: FieldIs, Value: IsArchived, Negate: true},
+			},
+		},
+		{
+			name:  "Dates",
+			input: "before:2025-01-31 after:2025-01-31",
+			want: []Term{
+				{Field: FieldBefore, Value: "2025-01-31", Time: day},
+				{Field: FieldAfter, Value: "2025-01-31", Time: day},
+			},
+		},
+		{
+			name:  "Title phrase",
+			input: `title:"weekly sync"`,
+			want:  []Term{{Field: FieldTitle, Value: "weekly sync", Phrase: true}},
+		},
+		{
+			name:  "Unknown field is text",
+			input: "https://example.com",
+			want:  []Term{{Value: "https://example.com"}},
+		},
+		{
+			name:  "No-break space",
+			input: "foo\u00a0bar",
+			want:  []Term{{Value: "foo"}, {Value: "bar"}},
+		},
+		{
+			name:  "Em space",
+			input: "tag:work\u2003bread",
+			want:  []Term{{Field: FieldTag, Value: "work"}, {Value: "bread"}},
+		},
+		{
+			name:  "Ideographic space",
+			input: "会議\u3000メモ\u3000",
+			want:  []Term{{Value: "会議"}, {Value: "メモ"}},
+		},
+		{
+			name:  "Lone dash and empty quotes",
+			input: `- "" bread`,
+			want:  []Term{{Value: "bread"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			query, err := Parse(tt.input, time.UTC)
+			assert.NoError(t, err)
+			assert.EqualSlices(t, tt.want, query.Terms)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `bread "sourdough`, want: "missing closing quote at character 7"},
+		{input: "bread tag:", want: "tag: needs a value at character 7"},
+		{input: "is:done", want: "unknown is:done, use is:favorite or is:archived at character 1"},
+		{input: "café before:yesterday", want: "before:yesterday is not a date like 2025-01-31 at character 6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse(tt.input, time.UTC)
+			assert.NotEqual(t, nil, err)
+			assert.Equal(t, tt.want, err.Error())
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Empty", func(t *testing.T) {
+		sql := Query{}.Compile("english")
+		assert.Equal(t, "NOT archive", sql.Where)
+		assert.Equal(t, "", sql.TSQuery)
+		assert.Equal(t, 0, len(sql.Args))
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		query, err := Parse(`bread -rye "sour dough"`, time.UTC)
+		assert.NoError(t, err)
+		assert.Equal(t, `bread -rye "sour dough"`, query.Text())
+
+		sql := query.Compile("english")
+		assert.Equal(t, "websearch_to_tsquery($2::regconfig, $1::text)", sql.TSQuery)
+		assert.Equal(t, "("+Vector+" @@ websearch_to_tsquery($2::regconfig, $1::text) OR id = $1::text)\n    AND NOT archive", sql.Where)
+		assert.EqualSlices(t, []any{`bread -rye "sour dough"`, "english"}, sql.Args)
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		query, err := Parse(`tag:work -is:favorite is:archived title:"weekly sync" after:2025-01-31`, time.UTC)
+		assert.NoError(t, err)
+
+		sql := query.Compile("english")
+		assert.Equal(t, "", sql.TSQuery)
+		assert.Equal(t, "EXISTS (SELECT 1 FROM unnest(tags) AS tag WHERE tag = $1::text OR starts_with(tag, $1::text || '/'))"+
+			"\n    AND NOT (favorite)"+
+			"\n    AND archive"+
+			"\n    AND to_tsvector(search_language, title) @@ websearch_to_tsquery(search_language, $2::text)"+
+			"\n    AND created_at >= $3::timestamptz", sql.Where)
+		assert.EqualSlices(t, []any{"work", `"weekly sync"`, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)}, sql.Args)
+	})
+
+	t.Run("Any", func(t *testing.T) {
+		query := Query{
+			Terms: []Term{{Field: FieldTag, Value: "work", Negate: true}},
+			Any:   []Term{{Field: FieldTag, Value: "recipe"}, {Field: FieldIs, Value: IsFavorite}, {Value: "ignored"}},
+		}
+		sql := query.Compile("english")
+		assert.Equal(t, "NOT (EXISTS (SELECT 1 FROM unnest(tags) AS tag WHERE tag = $1::text OR starts_with(tag, $1::text || '/')))"+
+			"\n    AND (EXISTS (SELECT 1 FROM unnest(tags) AS tag WHERE tag = $2::text OR starts_with(tag, $2::text || '/')) OR favorite)"+
+			"\n    AND NOT archive", sql.Where)
+		assert.EqualSlices(t, []any{"work", "recipe"}, sql.Args)
+	})
+
+	t.Run("Excluded archive", func(t *testing.T) {
+		query, err := Parse("-is:archived", time.UTC)
+		assert.NoError(t, err)
+		assert.Equal(t, "NOT (archive)\n    AND NOT archive", query.Compile("english").Where)
+	})
+}
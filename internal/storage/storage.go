@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sglmr/go-notes/db"
+)
+
+// ErrNotFound is returned when a file does not exist in a Store
+var ErrNotFound = errors.New("file not found")
+
+// Store enables exchanging where attachment files are kept, like a DirStore or DBStore.
+type Store interface {
+	Save(ctx context.Context, id string, r io.Reader) error
+	Open(ctx context.Context, id string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, id string) error
+}
+
+//=============================================================================
+// Local directory store
+//=============================================================================
+
+// DirStore keeps files in a directory on the local file system
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore initializes a new DirStore and creates the directory if it doesn't exist
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create storage directory: %w", err)
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+// Save writes the contents of r to a file named id
+func (s *DirStore) Save(ctx context.Context, id string, r io.Reader) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a failed upload doesn't leave a partial file behind
+	f, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Open opens the file named id for reading
+func (s *DirStore) Open(ctx context.Context, id string) (io.ReadSeekCloser, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete removes the file named id. Deleting a file that doesn't exist is not an error.
+func (s *DirStore) Delete(ctx context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path returns the path of the file named id in the store directory
+func (s *DirStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid file id %q", id)
+	}
+	return filepath.Join(s.dir, id), nil
+}
+
+//=============================================================================
+// PostgreSQL store
+//=============================================================================
+
+// DBStore keeps files in PostgreSQL as bytea
+type DBStore struct {
+	queries *db.Queries
+}
+
+// NewDBStore initializes a new DBStore
+func NewDBStore(queries *db.Queries) *DBStore {
+	return &DBStore{queries: queries}
+}
+
+// Save writes the contents of r to the database
+func (s *DBStore) Save(ctx context.Context, id string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return s.queries.SaveAttachmentData(ctx, db.SaveAttachmentDataParams{AttachmentID: id, Data: data})
+}
+
+// Open reads the file named id from the database
+func (s *DBStore) Open(ctx context.Context, id string) (io.ReadSeekCloser, error) {
+	data, err := s.queries.GetAttachmentData(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+// Delete removes the file named id from the database
+func (s *DBStore) Delete(ctx context.Context, id string) error {
+	return s.queries.DeleteAttachmentData(ctx, id)
+}
+
+// nopCloser adds a no-op Close method to an io.ReadSeeker
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
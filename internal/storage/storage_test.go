@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sglmr/go-notes/internal/assert"
+)
+
+func TestDirStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	store, err := NewDirStore(t.TempDir() + "/attachments")
+	assert.NoError(t, err)
+
+	// Save and read back a file
+	err = store.Save(ctx, "a_123", strings.NewReader("hello world"))
+	assert.NoError(t, err)
+
+	f, err := store.Open(ctx, "a_123")
+	assert.NoError(t, err)
+	data, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.Equal(t, "hello world", string(data))
+
+	// Saving again replaces the file
+	err = store.Save(ctx, "a_123", strings.NewReader("goodbye"))
+	assert.NoError(t, err)
+
+	f, err = store.Open(ctx, "a_123")
+	assert.NoError(t, err)
+	data, err = io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.Equal(t, "goodbye", string(data))
+
+	// Delete the file
+	assert.NoError(t, store.Delete(ctx, "a_123"))
+	_, err = store.Open(ctx, "a_123")
+	assert.Equal(t, true, errors.Is(err, ErrNotFound))
+
+	// Deleting a missing file is not an error
+	assert.NoError(t, store.Delete(ctx, "a_123"))
+
+	// IDs can't escape the storage directory
+	for _, id := range []string{"", "../a_123", "a/b", ".hidden"} {
+		err = store.Save(ctx, id, strings.NewReader("nope"))
+		assert.NotEqual(t, nil, err)
+	}
+}
@@ -0,0 +1,158 @@
+package tasklist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// ErrNotTask is returned when a line of a note is not a checklist item
+var ErrNotTask = errors.New("line is not a checklist item")
+
+// taskRX matches a "- [ ] task" checklist line. The groups are the list marker,
+// the check mark and the task text.
+var taskRX = regexp.MustCompile(`^(\s*(?:[-+*]|\d+[.)])\s+\[)([ xX])(\].*)$`)
+
+// dueRX matches an @due(2026-10-20) date in a task
+var dueRX = regexp.MustCompile(`@due\((\d{4}-\d{2}-\d{2})\)`)
+
+// Item is a checklist item in a note
+type Item struct {
+	Line    int
+	Text    string
+	Checked bool
+	Due     *time.Time
+}
+
+// Items returns the checklist items in the markdown source in the order they appear.
+// Checklists inside code blocks are ignored.
+func Items(source string) []Item {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+	lines := strings.Split(source, "\n")
+
+	items := []Item{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		node, ok := n.(*extast.TaskCheckBox)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		line, ok := checkBoxLine(src, node)
+		if !ok || line >= len(lines) {
+			return ast.WalkContinue, nil
+		}
+		m := taskRX.FindStringSubmatch(strings.TrimRight(lines[line], "\r"))
+		if m == nil {
+			return ast.WalkContinue, nil
+		}
+
+		item := Item{Line: line, Checked: node.IsChecked, Text: m[3][1:]}
+
+		// Pull the due date out of the task text
+		if due := dueRX.FindStringSubmatch(item.Text); due != nil {
+			if t, err := time.Parse("2006-01-02", due[1]); err == nil {
+				item.Due = &t
+			}
+			item.Text = dueRX.ReplaceAllString(item.Text, "")
+		}
+		item.Text = strings.Join(strings.Fields(item.Text), " ")
+
+		items = append(items, item)
+		return ast.WalkContinue, nil
+	})
+
+	return items
+}
+
+// Toggle checks or unchecks the checklist item on a line (counting from 0) of the markdown source
+func Toggle(source string, line int, checked bool) (string, error) {
+	// Only change lines that render as checklist items, not ones in code blocks
+	if !slices.ContainsFunc(Items(source), func(item Item) bool { return item.Line == line }) {
+		return "", ErrNotTask
+	}
+	lines := strings.Split(source, "\n")
+
+	m := taskRX.FindStringSubmatchIndex(lines[line])
+	if m == nil {
+		return "", ErrNotTask
+	}
+
+	mark := " "
+	if checked {
+		mark = "x"
+	}
+	lines[line] = lines[line][:m[4]] + mark + lines[line][m[5]:]
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// checkBoxLine returns the line number (counting from 0) of a task checkbox in the source
+func checkBoxLine(source []byte, n ast.Node) (int, bool) {
+	parent := n.Parent()
+	if parent == nil || parent.Lines().Len() == 0 {
+		return 0, false
+	}
+	start := parent.Lines().At(0).Start
+	return bytes.Count(source[:start], []byte("\n")), true
+}
+
+//=============================================================================
+// Renderer
+//=============================================================================
+
+type taskCheckBoxRenderer struct{}
+
+// RegisterFuncs registers the task checkbox render function
+func (r *taskCheckBoxRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(extast.KindTaskCheckBox, r.render)
+}
+
+// render writes a disabled checkbox like the GFM renderer, with the source line of the task
+// in a data-task-line attribute so a page can turn it into a working checkbox
+func (r *taskCheckBoxRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	node := n.(*extast.TaskCheckBox)
+
+	w.WriteString(`<input`)
+	if node.IsChecked {
+		w.WriteString(` checked=""`)
+	}
+	w.WriteString(` disabled="" type="checkbox"`)
+	if line, ok := checkBoxLine(source, node); ok {
+		fmt.Fprintf(w, ` data-task-line="%d"`, line)
+	}
+	w.WriteString("> ")
+
+	return ast.WalkContinue, nil
+}
+
+//=============================================================================
+// Extension
+//=============================================================================
+
+// Extension is a goldmark extension that renders GFM task list checkboxes with their source line
+type Extension struct{}
+
+// Extend adds the task checkbox renderer to a goldmark.Markdown. It replaces the
+// checkbox renderer from the GFM task list extension (priority 500).
+func (e *Extension) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&taskCheckBoxRenderer{}, 499),
+	))
+}
@@ -0,0 +1,170 @@
+package tasklist
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sglmr/go-notes/internal/assert"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "Open task",
+			input: "- [ ] Buy milk",
+			want:  "<ul>\n<li><input disabled=\"\" type=\"checkbox\" data-task-line=\"0\"> Buy milk</li>\n</ul>",
+		},
+		{
+			name:  "Done task on a later line",
+			input: "# Shopping\n\n- [ ] Buy milk\n- [x] Buy eggs",
+			want:  "<h1>Shopping</h1>\n<ul>\n<li><input disabled=\"\" type=\"checkbox\" data-task-line=\"2\"> Buy milk</li>\n<li><input checked=\"\" disabled=\"\" type=\"checkbox\" data-task-line=\"3\"> Buy eggs</li>\n</ul>",
+		},
+	}
+
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM, &Extension{}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := new(bytes.Buffer)
+			if err := md.Convert([]byte(tt.input), buf); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want, string(bytes.TrimSpace(buf.Bytes())))
+		})
+	}
+}
+
+func TestItems(t *testing.T) {
+	t.Parallel()
+
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []Item
+	}{
+		{
+			name:  "No tasks",
+			input: "- just a list",
+			want:  []Item{},
+		},
+		{
+			name:  "Open and done tasks",
+			input: "Intro\n\n- [ ] Buy milk\n  - [X] Check the fridge\n1. [ ] Numbered",
+			want: []Item{
+				{Line: 2, Text: "Buy milk"},
+				{Line: 3, Text: "Check the fridge", Checked: true},
+				{Line: 4, Text: "Numbered"},
+			},
+		},
+		{
+			name:  "Due dates",
+			input: "- [ ] Pay rent @due(2026-10-20) today\n- [ ] Bad date @due(2026-13-40)",
+			want: []Item{
+				{Line: 0, Text: "Pay rent today", Due: &due},
+				{Line: 1, Text: "Bad date"},
+			},
+		},
+		{
+			name:  "Skips code blocks",
+			input: "```\n- [ ] not a task\n```\n\n- [ ] a task",
+			want:  []Item{{Line: 4, Text: "a task"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := Items(tt.input)
+			assert.Equal(t, len(tt.want), len(got))
+			for i := range min(len(tt.want), len(got)) {
+				assert.Equal(t, tt.want[i].Line, got[i].Line)
+				assert.Equal(t, tt.want[i].Text, got[i].Text)
+				assert.Equal(t, tt.want[i].Checked, got[i].Checked)
+				assert.Equal(t, tt.want[i].Due == nil, got[i].Due == nil)
+				if tt.want[i].Due != nil && got[i].Due != nil {
+					assert.Equal(t, *tt.want[i].Due, *got[i].Due)
+				}
+			}
+		})
+	}
+}
+
+func TestToggle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		line    int
+		checked bool
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "Check a task",
+			input:   "# List\n\n- [ ] one\n- [ ] two",
+			line:    3,
+			checked: true,
+			want:    "# List\n\n- [ ] one\n- [x] two",
+		},
+		{
+			name:  "Uncheck a task",
+			input: "* [X] one\r\n* [ ] two",
+			line:  0,
+			want:  "* [ ] one\r\n* [ ] two",
+		},
+		{
+			name:    "Checking a done task keeps it done",
+			input:   "- [x] one",
+			line:    0,
+			checked: true,
+			want:    "- [x] one",
+		},
+		{
+			name:    "Not a task",
+			input:   "# List\n\n- [ ] one",
+			line:    0,
+			checked: true,
+			wantErr: ErrNotTask,
+		},
+		{
+			name:    "Line out of range",
+			input:   "- [ ] one",
+			line:    5,
+			checked: true,
+			wantErr: ErrNotTask,
+		},
+		{
+			name:    "Tasks in code blocks are not changed",
+			input:   "```\n- [ ] one\n```",
+			line:    1,
+			checked: true,
+			wantErr: ErrNotTask,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Toggle(tt.input, tt.line, tt.checked)
+			assert.Equal(t, true, errors.Is(err, tt.wantErr))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
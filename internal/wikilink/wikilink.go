@@ -0,0 +1,247 @@
+package wikilink
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Note is the note that a wiki link resolves to
+type Note struct {
+	ID    string
+	Title string
+}
+
+// Resolver looks up the notes for the wiki link targets in a document. A target is either
+// a note ID or a note title. It returns the notes that exist by their targets.
+type Resolver func(targets []string) map[string]Note
+
+//=============================================================================
+// AST Node
+//=============================================================================
+
+// KindWikiLink is the ast.NodeKind of a wiki link
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// Node is an inline ast node for a [[Target]] or [[Target|Label]] wiki link
+type Node struct {
+	ast.BaseInline
+	Target string
+	Label  string
+
+	// Segment is the position of the whole link in the source
+	Segment text.Segment
+
+	// Note is the note the link resolves to, or nil if it doesn't exist. It is only set
+	// when the Extension has a Resolver.
+	Note *Note
+}
+
+// Kind returns the ast.NodeKind of the wiki link
+func (n *Node) Kind() ast.NodeKind {
+	return KindWikiLink
+}
+
+// Dump dumps the wiki link node for debugging
+func (n *Node) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Target, "Label": n.Label}, nil)
+}
+
+//=============================================================================
+// Parser
+//=============================================================================
+
+type wikiLinkParser struct{}
+
+// Trigger returns the characters that start a wiki link
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+// Parse parses a [[Target|Label]] wiki link from the current position of the block
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+
+	// Find the closing brackets on the same line
+	end := bytes.Index(line[2:], []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	inner := string(line[2 : 2+end])
+	if strings.ContainsAny(inner, "[]") {
+		return nil
+	}
+
+	// Split out an optional label
+	target, label, _ := strings.Cut(inner, "|")
+	target = strings.TrimSpace(target)
+	label = strings.TrimSpace(label)
+	if target == "" {
+		return nil
+	}
+
+	block.Advance(end + 4)
+	return &Node{Target: target, Label: label, Segment: text.NewSegment(segment.Start, segment.Start+end+4)}
+}
+
+//=============================================================================
+// AST Transformer
+//=============================================================================
+
+type wikiLinkTransformer struct {
+	resolver Resolver
+}
+
+// Transform resolves all the wiki links in the document with one call to the resolver
+func (t *wikiLinkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	links := []*Node{}
+	targets := []string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if node, ok := n.(*Node); ok && entering {
+			links = append(links, node)
+			if !slices.Contains(targets, node.Target) {
+				targets = append(targets, node.Target)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(targets) == 0 {
+		return
+	}
+
+	notes := t.resolver(targets)
+	for _, link := range links {
+		if note, ok := notes[link.Target]; ok {
+			link.Note = &note
+		}
+	}
+}
+
+//=============================================================================
+// Renderer
+//=============================================================================
+
+type wikiLinkRenderer struct{}
+
+// RegisterFuncs registers the wiki link render function
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.render)
+}
+
+// render writes a link to the note, or a link to create the note if it doesn't exist
+func (r *wikiLinkRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	node := n.(*Node)
+
+	// Choose the text for the link
+	label := node.Label
+	switch {
+	case label != "":
+	case node.Note != nil && node.Note.Title != "":
+		label = node.Note.Title
+	default:
+		label = node.Target
+	}
+
+	if node.Note != nil {
+		fmt.Fprintf(w, `<a href="/note/%s/" class="wikilink">`, url.PathEscape(node.Note.ID))
+	} else {
+		fmt.Fprintf(w, `<a href="/notes/new/?title=%s" class="wikilink wikilink-missing" title="Create this note">`, url.QueryEscape(node.Target))
+	}
+	w.Write(util.EscapeHTML([]byte(label)))
+	w.WriteString("</a>")
+
+	return ast.WalkSkipChildren, nil
+}
+
+//=============================================================================
+// Extension
+//=============================================================================
+
+// Extension is a goldmark extension for [[wiki links]] between notes
+type Extension struct {
+	Resolver Resolver
+}
+
+// Extend adds the wiki link parser and renderer to a goldmark.Markdown
+func (e *Extension) Extend(m goldmark.Markdown) {
+	// Parse wiki links before the standard link parser (priority 200) sees the brackets
+	m.Parser().AddOptions(parser.WithInlineParsers(
+		util.Prioritized(&wikiLinkParser{}, 199),
+	))
+	if e.Resolver != nil {
+		m.Parser().AddOptions(parser.WithASTTransformers(
+			util.Prioritized(&wikiLinkTransformer{resolver: e.Resolver}, 999),
+		))
+	}
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&wikiLinkRenderer{}, 199),
+	))
+}
+
+// Targets returns the unique wiki link targets in the markdown source.
+// Wiki links inside code blocks and code spans are ignored.
+func Targets(source string) []string {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM, &Extension{}))
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	targets := []string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if node, ok := n.(*Node); ok && entering && !slices.Contains(targets, node.Target) {
+			targets = append(targets, node.Target)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return targets
+}
+
+// Rewrite returns the markdown source with some of its wiki links replaced. replace returns
+// the new text for a link, like Link(target, label), or false to keep the link. Wiki links
+// inside code blocks and code spans are kept.
+func Rewrite(source string, replace func(link *Node) (string, bool)) string {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM, &Extension{}))
+	doc := md.Parser().Parse(text.NewReader([]byte(source)))
+
+	var b strings.Builder
+	last := 0
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		node, ok := n.(*Node)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		if link, ok := replace(node); ok {
+			b.WriteString(source[last:node.Segment.Start])
+			b.WriteString(link)
+			last = node.Segment.Stop
+		}
+		return ast.WalkContinue, nil
+	})
+	b.WriteString(source[last:])
+
+	return b.String()
+}
+
+// Link returns the markdown for a wiki link to the target with an optional label
+func Link(target, label string) string {
+	if label == "" {
+		return "[[" + target + "]]"
+	}
+	return "[[" + target + "|" + label + "]]"
+}
@@ -0,0 +1,182 @@
+package wikilink
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sglmr/go-notes/internal/assert"
+	"github.com/yuin/goldmark"
+)
+
+// testResolver resolves a couple of notes by ID or title
+func testResolver(targets []string) map[string]Note {
+	notes := map[string]Note{}
+	for _, target := range targets {
+		switch target {
+		case "n_123", "Weekend Plans":
+			notes[target] = Note{ID: "n_123", Title: "Weekend Plans"}
+		}
+	}
+	return notes
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "Link by title",
+			input: "See [[Weekend Plans]]",
+			want:  `<p>See <a href="/note/n_123/" class="wikilink">Weekend Plans</a></p>`,
+		},
+		{
+			name:  "Link by ID uses the note title",
+			input: "See [[n_123]]",
+			want:  `<p>See <a href="/note/n_123/" class="wikilink">Weekend Plans</a></p>`,
+		},
+		{
+			name:  "Link with a label",
+			input: "See [[n_123|the plans]]",
+			want:  `<p>See <a href="/note/n_123/" class="wikilink">the plans</a></p>`,
+		},
+		{
+			name:  "Missing note links to create the note",
+			input: "See [[New Idea & More]]",
+			want:  `<p>See <a href="/notes/new/?title=New+Idea+%26+More" class="wikilink wikilink-missing" title="Create this note">New Idea &amp; More</a></p>`,
+		},
+		{
+			name:  "Regular links still work",
+			input: "See [plans](/note/n_123/)",
+			want:  `<p>See <a href="/note/n_123/">plans</a></p>`,
+		},
+		{
+			name:  "Empty brackets are not a link",
+			input: "See [[ ]]",
+			want:  `<p>See [[ ]]</p>`,
+		},
+		{
+			name:  "Code spans are not links",
+			input: "See `[[Weekend Plans]]`",
+			want:  `<p>See <code>[[Weekend Plans]]</code></p>`,
+		},
+	}
+
+	md := goldmark.New(goldmark.WithExtensions(&Extension{Resolver: testResolver}))
+
+	// All the links in a document are resolved at once
+	calls := 0
+	counter := goldmark.New(goldmark.WithExtensions(&Extension{Resolver: func(targets []string) map[string]Note {
+		calls++
+		assert.EqualSlices(t, []string{"n_123", "Weekend Plans", "Other"}, targets)
+		return testResolver(targets)
+	}}))
+	if err := counter.Convert([]byte("[[n_123]] [[Weekend Plans]] [[Other]] [[n_123]]"), new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, calls)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := new(bytes.Buffer)
+			if err := md.Convert([]byte(tt.input), buf); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want, string(bytes.TrimSpace(buf.Bytes())))
+		})
+	}
+}
+
+func TestTargets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "No links",
+			input: "Just some text",
+			want:  []string{},
+		},
+		{
+			name:  "Titles and IDs",
+			input: "See [[Weekend Plans]] and [[n_123|this]]",
+			want:  []string{"Weekend Plans", "n_123"},
+		},
+		{
+			name:  "No duplicates",
+			input: "[[One]] then [[One]] again",
+			want:  []string{"One"},
+		},
+		{
+			name:  "Skips code blocks",
+			input: "[[One]]\n\n```\n[[Two]]\n```\n\n`[[Three]]`",
+			want:  []string{"One"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.EqualSlices(t, tt.want, Targets(tt.input))
+		})
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	t.Parallel()
+
+	// Point the links to n_old at n_new, keeping what the links show
+	replace := func(link *Node) (string, bool) {
+		switch {
+		case link.Target == "n_old":
+			return Link("n_new", link.Label), true
+		case link.Target == "Old Title" && link.Label == "":
+			return Link("n_new", link.Target), true
+		case link.Target == "Old Title":
+			return Link("n_new", link.Label), true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "No links",
+			input: "Just some text",
+			want:  "Just some text",
+		},
+		{
+			name:  "IDs and titles",
+			input: "See [[n_old]], [[ n_old | this ]] and [[Old Title]].\n\n- [[Other]] [[Old Title|that]]",
+			want:  "See [[n_new]], [[n_new|this]] and [[n_new|Old Title]].\n\n- [[Other]] [[n_new|that]]",
+		},
+		{
+			name:  "Skips code",
+			input: "`[[n_old]]`\n\n```\n[[n_old]]\n```\n[[n_old]]",
+			want:  "`[[n_old]]`\n\n```\n[[n_old]]\n```\n[[n_new]]",
+		},
+		{
+			name:  "Unicode before the link",
+			input: "Café — [[n_old]]",
+			want:  "Café — [[n_new]]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, Rewrite(tt.input, replace))
+		})
+	}
+}